@@ -0,0 +1,279 @@
+// Package main реализует replay — утилиту дежурного для разбора инцидентов
+// доставки. Она читает топик шлюза по диапазону оффсетов или времени,
+// декодирует записи как domain.Message, фильтрует их по отправителю,
+// получателю или идентификатору и выводит найденное в формате JSON Lines.
+// С флагом -republish выбранные записи публикуются повторно, при
+// необходимости в другой топик. Читать и публиковать можно только топики
+// из kafka.topics (по логическому или реальному имени), их топики повторов
+// и DLQ.
+//
+// Примеры:
+//
+//	replay -since 2025-01-02T10:00:00Z -until 2025-01-02T10:15:00Z -recipient <uuid>
+//	replay -partition 3 -from-offset 1200 -to-offset 1300 -republish
+//	replay -topic chat.messages.dlq -id <uuid> -republish -target-topic messages
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/kafka"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/logger"
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+// errLimitReached прерывает чтение, когда найдено достаточно записей.
+var errLimitReached = errors.New("limit reached")
+
+type options struct {
+	configPath  string
+	topic       string
+	partition   int
+	fromOffset  int64
+	toOffset    int64
+	since       string
+	until       string
+	sender      string
+	recipient   string
+	messageID   string
+	limit       int
+	republish   bool
+	targetTopic string
+}
+
+// filter отбирает записи по полям domain.Message. Пустое поле не фильтрует.
+type filter struct {
+	sender    string
+	recipient string
+	messageID string
+}
+
+// output — строка отчёта по найденной записи.
+type output struct {
+	Topic       string            `json:"topic"`
+	Partition   int               `json:"partition"`
+	Offset      int64             `json:"offset"`
+	Time        time.Time         `json:"time"`
+	Key         string            `json:"key,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Message     *domain.Message   `json:"message,omitempty"`
+	Raw         string            `json:"raw,omitempty"`
+	Republished string            `json:"republished_to,omitempty"`
+}
+
+func main() {
+	opts := parseFlags()
+	log := slog.New(logger.NewPrettyHandler(os.Stderr, logger.PrettyHandlerOptions{}))
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	if err := run(ctx, log, opts, os.Stdout); err != nil {
+		log.Error("Replay failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+}
+
+func parseFlags() *options {
+	opts := &options{}
+	flag.StringVar(&opts.configPath, "config", "config/config.yaml", "path to gateway config")
+	flag.StringVar(&opts.topic, "topic", "", "configured topic, its retry or DLQ topic, or logical name from kafka.topics to read (defaults to messages)")
+	flag.IntVar(&opts.partition, "partition", -1, "partition to read, -1 for all")
+	flag.Int64Var(&opts.fromOffset, "from-offset", -1, "first offset to read, -1 for the earliest")
+	flag.Int64Var(&opts.toOffset, "to-offset", -1, "last offset to read (inclusive), -1 for the latest")
+	flag.StringVar(&opts.since, "since", "", "read records produced at or after this RFC3339 time")
+	flag.StringVar(&opts.until, "until", "", "stop at records produced after this RFC3339 time")
+	flag.StringVar(&opts.sender, "sender", "", "match sender user id")
+	flag.StringVar(&opts.recipient, "recipient", "", "match recipient user id")
+	flag.StringVar(&opts.messageID, "id", "", "match message id")
+	flag.IntVar(&opts.limit, "limit", 0, "stop after this many matches, 0 for no limit")
+	flag.BoolVar(&opts.republish, "republish", false, "republish matched records")
+	flag.StringVar(&opts.targetTopic, "target-topic", "", "configured topic, its retry or DLQ topic, or logical name from kafka.topics to republish into (defaults to the source topic); other names are rejected")
+	flag.Parse()
+	return opts
+}
+
+func run(ctx context.Context, log *slog.Logger, opts *options, out io.Writer) error {
+	cfg := config.LoadConfig(opts.configPath)
+	if cfg.KafkaConfig == nil {
+		return errors.New("kafka config section is missing")
+	}
+
+	rng, err := scanRange(opts)
+	if err != nil {
+		return err
+	}
+
+	// Утилита не должна входить в consumer group шлюза и забирать его партиции.
+	kafkaCfg := *cfg.KafkaConfig
+	kafkaCfg.GroupID = ""
+
+	kfk := kafka.NewKafka(&kafka.KafkaDeps{Cfg: &kafkaCfg, Log: log})
+	if err := kfk.Start(ctx); err != nil {
+		return fmt.Errorf("start kafka: %w", err)
+	}
+	defer func() {
+		if err := kfk.Stop(context.Background()); err != nil {
+			log.Warn("Kafka stop failed", slog.String("error", err.Error()))
+		}
+	}()
+
+	topic, err := kfk.Topic(opts.topic)
+	if err != nil {
+		return err
+	}
+	target := topic
+	if opts.targetTopic != "" {
+		if target, err = kfk.Topic(opts.targetTopic); err != nil {
+			return err
+		}
+	}
+
+	match := filter{sender: opts.sender, recipient: opts.recipient, messageID: opts.messageID}
+	encoder := json.NewEncoder(out)
+	matched, republished := 0, 0
+
+	err = kfk.Scan(ctx, topic, rng, func(record kafka.Record) error {
+		message, decodeErr := decode(record.Value)
+		if !match.accepts(message, decodeErr) {
+			return nil
+		}
+
+		line := newOutput(record, message)
+		if opts.republish {
+			if err := kfk.Publish(ctx, replayMessage(record, target)); err != nil {
+				return fmt.Errorf("republish offset %d: %w", record.Offset, err)
+			}
+			line.Republished = target
+			republished++
+		}
+		if err := encoder.Encode(line); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+
+		matched++
+		if opts.limit > 0 && matched >= opts.limit {
+			return errLimitReached
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimitReached) {
+		return err
+	}
+
+	log.Info("Replay finished",
+		slog.String("topic", topic),
+		slog.Int("matched", matched),
+		slog.Int("republished", republished),
+	)
+	return nil
+}
+
+func scanRange(opts *options) (kafka.ScanRange, error) {
+	rng := kafka.ScanRange{
+		Partition:  opts.partition,
+		FromOffset: opts.fromOffset,
+		ToOffset:   opts.toOffset,
+	}
+
+	var err error
+	if opts.since != "" {
+		if rng.Since, err = time.Parse(time.RFC3339, opts.since); err != nil {
+			return rng, fmt.Errorf("parse -since: %w", err)
+		}
+	}
+	if opts.until != "" {
+		if rng.Until, err = time.Parse(time.RFC3339, opts.until); err != nil {
+			return rng, fmt.Errorf("parse -until: %w", err)
+		}
+	}
+	return rng, nil
+}
+
+func decode(value []byte) (*domain.Message, error) {
+	var message domain.Message
+	if err := json.Unmarshal(value, &message); err != nil {
+		return nil, fmt.Errorf("decode domain message: %w", err)
+	}
+	return &message, nil
+}
+
+// accepts проверяет запись по фильтру. Записи, которые не удалось
+// декодировать, проходят только при пустом фильтре.
+func (f filter) accepts(message *domain.Message, decodeErr error) bool {
+	if decodeErr != nil {
+		return f == filter{}
+	}
+	if f.sender != "" && message.With != f.sender {
+		return false
+	}
+	if f.recipient != "" && message.To != f.recipient {
+		return false
+	}
+	if f.messageID != "" && message.ID.String() != f.messageID {
+		return false
+	}
+	return true
+}
+
+// retryHeaders — заголовки, которыми шлюз помечает записи в топиках повторов
+// и DLQ. Повторно опубликованная запись обрабатывается заново: со старым
+// счётчиком попыток она сразу вернулась бы в DLQ, а по x-source-topic —
+// попала бы к обработчику исходного топика вместо -target-topic.
+var retryHeaders = []string{
+	kafka.HeaderAttempt,
+	kafka.HeaderNotBefore,
+	kafka.HeaderError,
+	kafka.HeaderSourceTopic,
+	kafka.HeaderSourcePartition,
+	kafka.HeaderSourceOffset,
+}
+
+// replayMessage готовит запись к повторной публикации в target: ключ, тело
+// и заголовки сохраняются, кроме служебных заголовков повторов.
+func replayMessage(record kafka.Record, target string) events.Message {
+	headers := maps.Clone(record.Headers)
+	for _, key := range retryHeaders {
+		delete(headers, key)
+	}
+	return events.Message{
+		Topic:   target,
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+	}
+}
+
+func newOutput(record kafka.Record, message *domain.Message) output {
+	line := output{
+		Topic:     record.Topic,
+		Partition: record.Partition,
+		Offset:    record.Offset,
+		Time:      record.Time,
+		Key:       string(record.Key),
+		Message:   message,
+	}
+	if message == nil {
+		line.Raw = string(record.Value)
+	}
+	if len(record.Headers) > 0 {
+		line.Headers = make(map[string]string, len(record.Headers))
+		for key, value := range record.Headers {
+			line.Headers[key] = string(value)
+		}
+	}
+	return line
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/kafka"
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+func TestReplayMessageStripsRetryHeaders(t *testing.T) {
+	record := kafka.Record{
+		Message: events.Message{
+			Topic: "chat.messages.dlq",
+			Key:   []byte("a:b"),
+			Value: []byte(`{}`),
+			Headers: map[string][]byte{
+				events.HeaderEventType:      []byte(events.EventMessageCreated),
+				events.HeaderTraceParent:    []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+				kafka.HeaderAttempt:         []byte("3"),
+				kafka.HeaderNotBefore:       []byte("1700000000000"),
+				kafka.HeaderError:           []byte("notify recipient: no sessions"),
+				kafka.HeaderSourceTopic:     []byte("chat.receipts"),
+				kafka.HeaderSourcePartition: []byte("2"),
+				kafka.HeaderSourceOffset:    []byte("41"),
+			},
+		},
+		Partition: 0,
+		Offset:    7,
+	}
+
+	got := replayMessage(record, "chat.messages")
+
+	if got.Topic != "chat.messages" {
+		t.Fatalf("topic = %q, want chat.messages", got.Topic)
+	}
+	if string(got.Key) != "a:b" || string(got.Value) != `{}` {
+		t.Fatalf("key/value = %q/%q, want the original record", got.Key, got.Value)
+	}
+	want := map[string][]byte{
+		events.HeaderEventType:   []byte(events.EventMessageCreated),
+		events.HeaderTraceParent: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+	}
+	if !maps.EqualFunc(got.Headers, want, func(a, b []byte) bool { return string(a) == string(b) }) {
+		t.Fatalf("headers = %q, want %q", got.Headers, want)
+	}
+	if _, ok := record.Headers[kafka.HeaderAttempt]; !ok {
+		t.Fatal("replayMessage modified the scanned record")
+	}
+}
@@ -14,7 +14,10 @@ import (
 	"github.com/DENFNC/devPractice/internal/app"
 )
 
-const configPath = "config/config.yaml"
+const (
+	configPath             = "config/config.yaml"
+	defaultShutdownTimeout = 30 * time.Second
+)
 
 func main() {
 	cfg := config.LoadConfig(configPath)
@@ -28,11 +31,11 @@ func main() {
 	app.StartAsync()
 
 	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, syscall.SIGINT)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 
 	<-sig
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(cfg))
 	defer cancel()
 
 	if err := app.Shutdown(ctx); err != nil {
@@ -44,6 +47,13 @@ func main() {
 	}
 }
 
+func shutdownTimeout(cfg *config.Config) time.Duration {
+	if cfg.AppConfig == nil || cfg.ShutdownTimeout <= 0 {
+		return defaultShutdownTimeout
+	}
+	return cfg.ShutdownTimeout
+}
+
 func initLogger() *slog.Logger {
 	logHandler := logger.NewPrettyHandler(os.Stdout, logger.PrettyHandlerOptions{})
 	logger := slog.New(logHandler)
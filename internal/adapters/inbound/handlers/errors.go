@@ -1,6 +1,11 @@
 package handlers
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+
+	ws "github.com/DENFNC/devPractice/internal/adapters/inbound/ws"
+)
 
 // HandlerError описывает типовую ошибку обработчиков входящих сообщений
 // с кодом, пользовательским сообщением и дополнительными деталями.
@@ -17,6 +22,15 @@ func (e HandlerError) Error() string {
 	return fmt.Sprintf("%s: %s (%s)", e.Code, e.Message, e.Details)
 }
 
+// ClientPayload реализует ws.ClientError, чтобы транспорт вернул ошибку клиенту.
+func (e HandlerError) ClientPayload() ws.ErrorPayload {
+	return ws.ErrorPayload{
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	}
+}
+
 // WithDetails возвращает копию ошибки, дополненную деталями.
 func (e HandlerError) WithDetails(err error) HandlerError {
 	if err == nil {
@@ -42,4 +56,28 @@ var (
 		Code:    "message_delivery_failed",
 		Message: "failed to deliver message",
 	}
+	// ErrReceiptPublishFailed отражает сбой публикации квитанции.
+	ErrReceiptPublishFailed = HandlerError{
+		Code:    "receipt_publish_failed",
+		Message: "failed to publish receipt",
+	}
+)
+
+var (
+	// errSenderMismatch описывает попытку отправить сообщение от имени другого пользователя.
+	errSenderMismatch = errors.New("sender does not match authenticated user")
+	// errRecipientMissing описывает сообщение без получателя.
+	errRecipientMissing = errors.New("message recipient is required")
+	// errClientReqIDTooLong описывает слишком длинный ключ идемпотентности.
+	errClientReqIDTooLong = errors.New("client_req_id is too long")
+	// errReceiptSenderMissing описывает квитанцию без отправителя сообщения.
+	errReceiptSenderMissing = errors.New("message sender is required")
+	// errReceiptMessageID описывает квитанцию с идентификатором, который не
+	// присвоен сервером.
+	errReceiptMessageID = errors.New("message id must be a server-assigned UUIDv7")
+	// errReceiptUnknownMessage описывает квитанцию на сообщение, которое не
+	// было отправлено пользователю сессии.
+	errReceiptUnknownMessage = errors.New("message was not sent to this user")
+	// errReceiptPeerMissing описывает запрос квитанций без собеседника.
+	errReceiptPeerMissing = errors.New("conversation peer is required")
 )
@@ -7,11 +7,14 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	ws "github.com/DENFNC/devPractice/internal/adapters/inbound/ws"
 	"github.com/DENFNC/devPractice/internal/dto"
+	"github.com/google/uuid"
 )
 
 // SessionStore описывает абстракцию для хранения активных WebSocket-сессий.
@@ -22,6 +25,9 @@ type SessionStore interface {
 	Remove(ctx context.Context, keys ...string) error
 }
 
+// maxClientReqIDLength ограничивает длину клиентского ключа идемпотентности.
+const maxClientReqIDLength = 128
+
 // MessageType описывает тип входящего WebSocket-сообщения, который используется
 // маршрутизатором для выбора подходящего обработчика.
 type MessageType string
@@ -35,7 +41,7 @@ const (
 // входящие обработчики. Реализация должна инкапсулировать бизнес-правила,
 // валидацию и работу с хранилищем.
 type MessageUsecase interface {
-	SendMessage(ctx context.Context, dto *dto.MessageCreatedEvent) error
+	SendMessage(ctx context.Context, dto *dto.MessageCreatedEvent) (*dto.MessageSendResult, error)
 }
 
 // MessageHandler обрабатывает события WebSocket и делегирует работу доменной
@@ -66,14 +72,59 @@ func NewSendMessageHandler(deps *MessageHandlerDeps) *MessageHandler {
 	return h
 }
 
-// SendMessage обрабатывает входящие конверты типа send_message.
-func (h *MessageHandler) SendMessage(ctx context.Context, _ *ws.Session, env ws.Envelope) error {
+// SendMessage обрабатывает входящие конверты типа send_message. Отправитель
+// определяется по аутентифицированной сессии: отправитель из payload (поле
+// JSON with) может быть пустым, а при несовпадении с пользователем сессии
+// сообщение отклоняется.
+// Клиент получает ack с идентификатором сообщения после публикации в шину
+// (со статусом accepted, если продюсер асинхронный, или queued, если
+// брокер недоступен и сообщение сохранено в локальной очереди) либо nack,
+// если сообщение не удалось ни опубликовать, ни сохранить.
+// Ключом идемпотентности служит client_req_id из payload длиной не больше
+// maxClientReqIDLength. Без него повторные отправки не отсекаются:
+// идентификатор запроса из конверта живёт только в пределах соединения.
+// Повтор, пришедший во время публикации исходного сообщения, получает ack
+// со статусом in_progress.
+func (h *MessageHandler) SendMessage(ctx context.Context, s *ws.Session, env ws.Envelope) error {
+	if s == nil {
+		return errors.New("send_message: session is nil")
+	}
+
 	var dto dto.MessageCreatedEvent
 	if err := json.Unmarshal(env.Payload, &dto); err != nil {
-		return fmt.Errorf("decode send_message payload: %w", err)
+		return ErrMessageInvalidPayload.WithDetails(err)
+	}
+	if dto.From != uuid.Nil && dto.From != s.UserID {
+		return ErrMessageValidationFailed.WithDetails(errSenderMismatch)
 	}
-	if err := h.usecase.SendMessage(ctx, &dto); err != nil {
-		return fmt.Errorf("usecase send message: %w", err)
+	if dto.To == uuid.Nil {
+		return ErrMessageValidationFailed.WithDetails(errRecipientMissing)
 	}
-	return nil
+	dto.From = s.UserID
+	dto.SenderSession = s.ID
+	if len(dto.ClientReqID) > maxClientReqIDLength {
+		return ErrMessageValidationFailed.WithDetails(errClientReqIDTooLong)
+	}
+
+	result, err := h.usecase.SendMessage(ctx, &dto)
+	if err != nil {
+		if result == nil {
+			return fmt.Errorf("usecase send message: %w", err)
+		}
+		slog.Warn("failed to publish message",
+			slog.String("session_id", s.ID.String()),
+			slog.String("message_id", result.MessageID.String()),
+			slog.String("error", err.Error()),
+		)
+		return s.Nack(ctx, env.RequestID, ws.NackPayload{
+			MessageID: result.MessageID.String(),
+			Status:    string(result.Status),
+			Error:     ErrMessageDeliveryFailed.ClientPayload(),
+		})
+	}
+
+	return s.Ack(ctx, env.RequestID, ws.AckPayload{
+		MessageID: result.MessageID.String(),
+		Status:    string(result.Status),
+	})
 }
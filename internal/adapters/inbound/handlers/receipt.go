@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	ws "github.com/DENFNC/devPractice/internal/adapters/inbound/ws"
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/DENFNC/devPractice/internal/dto"
+	"github.com/google/uuid"
+)
+
+const (
+	// MessageTypeReceived соответствует квитанции о получении сообщения.
+	MessageTypeReceived MessageType = "message_received"
+	// MessageTypeRead соответствует квитанции о прочтении сообщений вплоть
+	// до указанного.
+	MessageTypeRead MessageType = "message_read"
+	// MessageTypeGetReceipts соответствует запросу состояния квитанций.
+	MessageTypeGetReceipts MessageType = "get_receipts"
+	// MessageTypeReceipts — тип ответа на get_receipts.
+	MessageTypeReceipts MessageType = "receipts"
+
+	// maxReceiptClockSkew — допустимое расхождение часов узлов, на которое
+	// время в идентификаторе сообщения может опережать текущее.
+	maxReceiptClockSkew = time.Minute
+)
+
+// ReceiptUsecase задает контракт доменной логики квитанций.
+type ReceiptUsecase interface {
+	Acknowledge(ctx context.Context, in *dto.ReceiptEvent) (dto.SendStatus, error)
+	Receipts(ctx context.Context, sender, with uuid.UUID) (*dto.ReceiptState, error)
+}
+
+// ReceiptHandler обрабатывает квитанции получателя и запросы их состояния
+// от отправителя.
+type ReceiptHandler struct {
+	usecase ReceiptUsecase
+}
+
+// ReceiptHandlerDeps описывает зависимости обработчика квитанций.
+type ReceiptHandlerDeps struct {
+	Usecase ReceiptUsecase
+	Router  *ws.HandlerChain
+}
+
+// receiptPayload — тело конвертов message_received и message_read: from —
+// отправитель сообщения message_id.
+type receiptPayload struct {
+	MessageID uuid.UUID `json:"message_id"`
+	From      uuid.UUID `json:"from"`
+}
+
+// receiptsQuery — тело конверта get_receipts.
+type receiptsQuery struct {
+	With uuid.UUID `json:"with"`
+}
+
+// NewReceiptHandler регистрирует обработчики квитанций.
+func NewReceiptHandler(deps *ReceiptHandlerDeps) *ReceiptHandler {
+	h := &ReceiptHandler{
+		usecase: deps.Usecase,
+	}
+
+	{
+		deps.Router.HandleFunc(string(MessageTypeReceived), h.MessageReceived)
+		deps.Router.HandleFunc(string(MessageTypeRead), h.MessageRead)
+		deps.Router.HandleFunc(string(MessageTypeGetReceipts), h.GetReceipts)
+	}
+
+	return h
+}
+
+// MessageReceived обрабатывает конверты message_received: клиент получателя
+// подтверждает, что сообщение до него дошло.
+func (h *ReceiptHandler) MessageReceived(ctx context.Context, s *ws.Session, env ws.Envelope) error {
+	return h.acknowledge(ctx, s, env, dto.ReceiptReceived)
+}
+
+// MessageRead обрабатывает конверты message_read: получатель прочитал
+// сообщения отправителя from вплоть до message_id.
+func (h *ReceiptHandler) MessageRead(ctx context.Context, s *ws.Session, env ws.Envelope) error {
+	return h.acknowledge(ctx, s, env, dto.ReceiptRead)
+}
+
+// acknowledge публикует квитанцию от имени пользователя сессии. Клиент
+// получает ack после публикации в шину (со статусом accepted, если шина
+// асинхронная) либо nack, если она не удалась.
+// Квитанции на сообщения, которые не были отправлены пользователю сессии,
+// отклоняются как невалидные, а на сообщения, участники которых уже
+// забыты, подтверждаются со статусом ignored без публикации.
+func (h *ReceiptHandler) acknowledge(ctx context.Context, s *ws.Session, env ws.Envelope, kind dto.ReceiptKind) error {
+	if s == nil {
+		return fmt.Errorf("%s: session is nil", env.Type)
+	}
+
+	var payload receiptPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		return ErrMessageInvalidPayload.WithDetails(err)
+	}
+	if payload.From == uuid.Nil {
+		return ErrMessageValidationFailed.WithDetails(errReceiptSenderMissing)
+	}
+	if !serverMessageID(payload.MessageID) {
+		return ErrMessageValidationFailed.WithDetails(errReceiptMessageID)
+	}
+
+	status, err := h.usecase.Acknowledge(ctx, &dto.ReceiptEvent{
+		Kind:             kind,
+		MessageID:        payload.MessageID,
+		Sender:           payload.From,
+		Recipient:        s.UserID,
+		RecipientSession: s.ID,
+	})
+	if errors.Is(err, domain.ErrUnknownMessage) {
+		return ErrMessageValidationFailed.WithDetails(errReceiptUnknownMessage)
+	}
+	if err != nil {
+		slog.Warn("failed to publish receipt",
+			slog.String("session_id", s.ID.String()),
+			slog.String("message_id", payload.MessageID.String()),
+			slog.String("error", err.Error()),
+		)
+		return s.Nack(ctx, env.RequestID, ws.NackPayload{
+			MessageID: payload.MessageID.String(),
+			Status:    string(dto.SendStatusFailed),
+			Error:     ErrReceiptPublishFailed.ClientPayload(),
+		})
+	}
+
+	return s.Ack(ctx, env.RequestID, ws.AckPayload{
+		MessageID: payload.MessageID.String(),
+		Status:    string(status),
+	})
+}
+
+// GetReceipts обрабатывает конверты get_receipts и отвечает конвертом
+// receipts с состоянием квитанций по сообщениям пользователя сессии в
+// диалоге с with.
+func (h *ReceiptHandler) GetReceipts(ctx context.Context, s *ws.Session, env ws.Envelope) error {
+	if s == nil {
+		return errors.New("get_receipts: session is nil")
+	}
+
+	var query receiptsQuery
+	if err := json.Unmarshal(env.Payload, &query); err != nil {
+		return ErrMessageInvalidPayload.WithDetails(err)
+	}
+	if query.With == uuid.Nil {
+		return ErrMessageValidationFailed.WithDetails(errReceiptPeerMissing)
+	}
+
+	state, err := h.usecase.Receipts(ctx, s.UserID, query.With)
+	if err != nil {
+		return fmt.Errorf("usecase get receipts: %w", err)
+	}
+	return s.Reply(ctx, env.RequestID, string(MessageTypeReceipts), state)
+}
+
+// serverMessageID сообщает, может ли id быть идентификатором, который
+// присвоил сервер: UUIDv7 со временем создания не позже текущего.
+func serverMessageID(id uuid.UUID) bool {
+	if id.Version() != 7 {
+		return false
+	}
+	sec, nsec := id.Time().UnixTime()
+	return !time.Unix(sec, nsec).After(time.Now().Add(maxReceiptClockSkew))
+}
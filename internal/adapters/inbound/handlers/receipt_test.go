@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	ws "github.com/DENFNC/devPractice/internal/adapters/inbound/ws"
+	"github.com/DENFNC/devPractice/internal/dto"
+	"github.com/google/uuid"
+)
+
+// recordingReceipts запоминает квитанции, дошедшие до usecase'а.
+type recordingReceipts struct {
+	acknowledged []*dto.ReceiptEvent
+}
+
+func (r *recordingReceipts) Acknowledge(_ context.Context, in *dto.ReceiptEvent) (dto.SendStatus, error) {
+	r.acknowledged = append(r.acknowledged, in)
+	return dto.SendStatusPublished, nil
+}
+
+func (r *recordingReceipts) Receipts(context.Context, uuid.UUID, uuid.UUID) (*dto.ReceiptState, error) {
+	return &dto.ReceiptState{}, nil
+}
+
+// uuidV7At возвращает UUIDv7 со временем создания at.
+func uuidV7At(at time.Time) uuid.UUID {
+	id := uuid.New()
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(at.UnixMilli()))
+	copy(id[:6], ms[2:])
+	id[6] = id[6]&0x0f | 0x70
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+func TestServerMessageID(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		id   uuid.UUID
+		want bool
+	}{
+		{name: "nil", id: uuid.Nil, want: false},
+		{name: "random uuid", id: uuid.New(), want: false},
+		{name: "past v7", id: uuidV7At(now.Add(-time.Hour)), want: true},
+		{name: "current v7", id: uuidV7At(now), want: true},
+		{name: "v7 within clock skew", id: uuidV7At(now.Add(maxReceiptClockSkew / 2)), want: true},
+		{name: "future v7", id: uuidV7At(now.Add(2 * maxReceiptClockSkew)), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serverMessageID(tt.id); got != tt.want {
+				t.Fatalf("serverMessageID(%s) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcknowledgeRejectsForeignMessageIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		id   uuid.UUID
+	}{
+		{name: "random uuid", id: uuid.New()},
+		{name: "future v7", id: uuidV7At(time.Now().Add(time.Hour))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := &recordingReceipts{}
+			h := &ReceiptHandler{usecase: usecase}
+			payload, err := json.Marshal(receiptPayload{MessageID: tt.id, From: uuid.New()})
+			if err != nil {
+				t.Fatalf("marshal payload: %v", err)
+			}
+
+			err = h.MessageRead(context.Background(), &ws.Session{ID: uuid.New(), UserID: uuid.New()}, ws.Envelope{
+				Type:    string(MessageTypeRead),
+				Payload: payload,
+			})
+			if want := ErrMessageValidationFailed.WithDetails(errReceiptMessageID); !errors.Is(err, want) {
+				t.Fatalf("MessageRead = %v, want %v", err, want)
+			}
+			if len(usecase.acknowledged) != 0 {
+				t.Fatalf("usecase got %d receipts, want none", len(usecase.acknowledged))
+			}
+		})
+	}
+}
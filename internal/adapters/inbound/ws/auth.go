@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/domain"
+)
+
+const (
+	// bearerProtocol — служебный сабпротокол, после которого клиент передаёт
+	// токен во втором элементе Sec-WebSocket-Protocol: "bearer, <token>".
+	bearerProtocol = "bearer"
+	// accessTokenParam — имя query-параметра с токеном доступа.
+	accessTokenParam = "access_token"
+	// authRealm используется в заголовке WWW-Authenticate ответа 401.
+	authRealm = `Bearer realm="realtime-gateway"`
+	// authRetryAfter — пауза, которую клиенту предлагается выждать, если
+	// токен не удалось проверить из-за недоступности верификатора.
+	authRetryAfter = 5 * time.Second
+)
+
+// ErrMissingToken возвращается, если запрос на upgrade не содержит токен.
+var ErrMissingToken = errors.New("websocket: access token is missing")
+
+// TokenVerifier проверяет токен доступа и возвращает идентичность пользователя.
+// Реализации находятся в пакете outbound/auth.
+type TokenVerifier interface {
+	Verify(ctx context.Context, token string) (domain.Identity, error)
+}
+
+// bearerToken извлекает токен из запроса на upgrade. Источники проверяются
+// в порядке: заголовок Authorization, Sec-WebSocket-Protocol, query-параметр
+// access_token. Второй результат сообщает, что токен пришёл через сабпротокол
+// и сервер должен подтвердить клиенту протокол bearer.
+func bearerToken(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), false
+		}
+	}
+
+	var protocols []string
+	for _, value := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocols = append(protocols, strings.TrimSpace(protocol))
+		}
+	}
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == bearerProtocol {
+			return protocols[i+1], true
+		}
+	}
+
+	return r.URL.Query().Get(accessTokenParam), false
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", authRealm)
+	http.Error(w, "unauthorized", http.StatusUnauthorized)
+}
+
+// verifierUnavailable сообщает, что токен не был проверен из-за сбоя
+// верификатора, а не отклонён им.
+func verifierUnavailable(err error) bool {
+	return errors.Is(err, domain.ErrVerifierUnavailable) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// authUnavailable отвечает 503 с Retry-After, чтобы клиент повторил
+// подключение с тем же токеном, а не сбрасывал его, как после 401.
+func authUnavailable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(authRetryAfter.Seconds())))
+	http.Error(w, "authentication is temporarily unavailable", http.StatusServiceUnavailable)
+}
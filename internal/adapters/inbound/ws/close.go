@@ -0,0 +1,153 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gobwas/ws"
+)
+
+// Коды закрытия WebSocket-соединения. Коды 4xxx зарезервированы за
+// приложением.
+const (
+	// CloseNormal — штатное завершение сессии.
+	CloseNormal = ws.StatusNormalClosure
+	// CloseGoingAway — узел останавливается или соединение признано мёртвым.
+	CloseGoingAway = ws.StatusGoingAway
+	// CloseProtocolError — клиент нарушил протокол WebSocket.
+	CloseProtocolError = ws.StatusProtocolError
+	// ClosePolicyViolation — клиент нарушил правила шлюза, например не
+	// успевает читать сообщения.
+	ClosePolicyViolation = ws.StatusPolicyViolation
+	// CloseMessageTooBig — сообщение клиента превышает допустимый размер.
+	CloseMessageTooBig = ws.StatusMessageTooBig
+	// CloseInternalError — сессия не может продолжаться из-за сбоя сервера.
+	CloseInternalError = ws.StatusInternalServerError
+	// CloseAuthExpired — истёк срок действия токена сессии.
+	CloseAuthExpired ws.StatusCode = 4001
+	// CloseKicked — сессия принудительно завершена администратором.
+	CloseKicked ws.StatusCode = 4002
+)
+
+const (
+	defaultMaxMessageSize = 64 << 10
+	defaultCloseTimeout   = 5 * time.Second
+
+	// maxCloseReason — предел длины причины: тело управляющего кадра не
+	// превышает 125 байт, два из которых занимает код.
+	maxCloseReason = 123
+)
+
+// CloseWithReason начинает закрытие сессии: перестаёт принимать исходящие
+// сообщения, дописывает клиенту уже поставленные в очередь и отправляет
+// закрывающий кадр с кодом и причиной.
+// Соединение закрывается, когда клиент ответит своим закрывающим кадром,
+// истечёт CloseTimeout или будет отменён ctx. Метод не блокируется, поэтому
+// его можно вызывать из обработчиков сообщений сессии.
+func (s *Session) CloseWithReason(ctx context.Context, code ws.StatusCode, reason string) error {
+	if !sendableCloseCode(code) {
+		return fmt.Errorf("%w: %d", ErrInvalidCloseCode, code)
+	}
+
+	frame := &outboundFrame{op: ws.OpClose, payload: ws.NewCloseFrameBody(code, truncateReason(reason))}
+	if !s.shutdown(frame) {
+		return ErrSessionClosed
+	}
+
+	go func() {
+		<-s.writerDone
+
+		timer := time.NewTimer(s.closeTimeout)
+		defer timer.Stop()
+		select {
+		case <-s.peerClosed:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		_ = s.conn.Close()
+	}()
+	return nil
+}
+
+// CloseSession закрывает локальную сессию с указанным кодом и причиной.
+func CloseSession(ctx context.Context, sessionID string, code ws.StatusCode, reason string) error {
+	sessionsMu.RLock()
+	session := sessionPool[sessionID]
+	sessionsMu.RUnlock()
+
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
+	}
+
+	return session.CloseWithReason(ctx, code, reason)
+}
+
+// Close немедленно закрывает соединение без закрывающего кадра. Повторные
+// вызовы безопасны.
+func (s *Session) Close() error {
+	return s.closeWith(nil)
+}
+
+// closeWithStatus отправляет закрывающий кадр и закрывает соединение, не
+// дожидаясь ответа клиента. Используется там, где ждать ответ некому:
+// в ReadLoop и при отключении медленного клиента.
+func (s *Session) closeWithStatus(code ws.StatusCode, reason string) error {
+	return s.closeWith(&outboundFrame{op: ws.OpClose, payload: ws.NewCloseFrameBody(code, truncateReason(reason))})
+}
+
+// closeWith останавливает писателя, дожидаясь отправки frame, если он задан,
+// и закрывает соединение. Если закрытие уже начато, frame игнорируется.
+func (s *Session) closeWith(frame *outboundFrame) error {
+	s.shutdown(frame)
+
+	var err error
+	if frame == nil {
+		err = s.conn.Close()
+		<-s.writerDone
+	} else {
+		<-s.writerDone
+		err = s.conn.Close()
+	}
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("close websocket connection: %w", err)
+	}
+	return nil
+}
+
+// shutdown закрывает очередь отправки и сообщает писателю, какой кадр
+// отправить последним. Возвращает false, если закрытие уже было начато.
+func (s *Session) shutdown(frame *outboundFrame) bool {
+	started := false
+	s.closeOnce.Do(func() {
+		s.queueMu.Lock()
+		s.closeFrame = frame
+		close(s.done)
+		s.queueMu.Unlock()
+		started = true
+	})
+	return started
+}
+
+// echoCloseFrame формирует ответ на закрывающий кадр клиента, повторяя его код.
+func echoCloseFrame(code ws.StatusCode) *outboundFrame {
+	return &outboundFrame{op: ws.OpClose, payload: ws.NewCloseFrameBody(code, "")}
+}
+
+func sendableCloseCode(code ws.StatusCode) bool {
+	return code >= 1000 && code < 5000 && !code.IsNotUsed() && !code.IsProtocolReserved()
+}
+
+func truncateReason(reason string) string {
+	if len(reason) <= maxCloseReason {
+		return reason
+	}
+	reason = reason[:maxCloseReason]
+	for !utf8.ValidString(reason) {
+		reason = reason[:len(reason)-1]
+	}
+	return reason
+}
@@ -0,0 +1,265 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/gobwas/ws"
+	"github.com/google/uuid"
+)
+
+// pipeSession возвращает сессию с запущенным писателем и клиентский конец
+// её соединения.
+func pipeSession(t *testing.T, cfg *config.WebSocketConfig) (*Session, net.Conn) {
+	t.Helper()
+
+	server, client := net.Pipe()
+	s, err := NewSession(server, nil, uuid.New(), cfg)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = s.Close()
+	})
+	return s, client
+}
+
+// peerClose — закрывающий кадр, полученный клиентом.
+type peerClose struct {
+	code   ws.StatusCode
+	reason string
+	empty  bool
+	err    error
+}
+
+// readPeerClose читает кадры сервера на стороне клиента до закрывающего
+// и возвращает его код и причину. Остаток соединения вычитывается: запись
+// в net.Pipe, даже пустая, ждёт читателя.
+func readPeerClose(client net.Conn) <-chan peerClose {
+	result := make(chan peerClose, 1)
+	go func() {
+		defer func() { _, _ = io.Copy(io.Discard, client) }()
+
+		_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			frame, err := ws.ReadFrame(client)
+			if err != nil {
+				result <- peerClose{err: err}
+				return
+			}
+			if frame.Header.OpCode != ws.OpClose {
+				continue
+			}
+			if len(frame.Payload) == 0 {
+				result <- peerClose{empty: true}
+				return
+			}
+			code, reason := ws.ParseCloseFrameData(frame.Payload)
+			result <- peerClose{code: code, reason: reason}
+			return
+		}
+	}()
+	return result
+}
+
+// writeClientFrame асинхронно пишет маскированный кадр клиента: сервер
+// может закрыть соединение, не дочитав его.
+func writeClientFrame(client net.Conn, frame ws.Frame) {
+	go func() { _ = ws.WriteFrame(client, ws.MaskFrameInPlace(frame)) }()
+}
+
+// runReadLoop запускает ReadLoop сессии и возвращает канал с его результатом.
+func runReadLoop(s *Session) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- s.ReadLoop(context.Background()) }()
+	return done
+}
+
+func expectPeerClose(t *testing.T, got <-chan peerClose, code ws.StatusCode, reason string) {
+	t.Helper()
+
+	select {
+	case peer := <-got:
+		if peer.err != nil {
+			t.Fatalf("read close frame: %v", peer.err)
+		}
+		if peer.code != code || peer.reason != reason {
+			t.Fatalf("peer got close %d %q, want %d %q", peer.code, peer.reason, code, reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("peer did not receive a close frame")
+	}
+}
+
+func TestCloseWithReasonSendsCodeToPeer(t *testing.T) {
+	long := strings.Repeat("я", 100)
+
+	tests := []struct {
+		name       string
+		code       ws.StatusCode
+		reason     string
+		wantReason string
+	}{
+		{name: "normal", code: CloseNormal, reason: "bye", wantReason: "bye"},
+		{name: "going away", code: CloseGoingAway, reason: "server shutting down", wantReason: "server shutting down"},
+		{name: "policy violation", code: ClosePolicyViolation, reason: "slow consumer", wantReason: "slow consumer"},
+		{name: "internal error", code: CloseInternalError, reason: "failed to deliver pending events", wantReason: "failed to deliver pending events"},
+		{name: "auth expired", code: CloseAuthExpired, reason: "token expired", wantReason: "token expired"},
+		{name: "kicked", code: CloseKicked, reason: "kicked", wantReason: "kicked"},
+		{name: "long reason is truncated on a rune boundary", code: CloseNormal, reason: long, wantReason: long[:122]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, client := pipeSession(t, &config.WebSocketConfig{CloseTimeout: 50 * time.Millisecond})
+			got := readPeerClose(client)
+
+			if err := s.CloseWithReason(context.Background(), tt.code, tt.reason); err != nil {
+				t.Fatalf("CloseWithReason: %v", err)
+			}
+			expectPeerClose(t, got, tt.code, tt.wantReason)
+		})
+	}
+}
+
+func TestCloseWithReasonRejectsUnsendableCodes(t *testing.T) {
+	for _, code := range []ws.StatusCode{999, ws.StatusNoStatusRcvd, ws.StatusAbnormalClosure, ws.StatusTLSHandshake, 5000} {
+		s := newTestSession(t, 1, OverflowDropOldest)
+		if err := s.CloseWithReason(context.Background(), code, "reason"); !errors.Is(err, ErrInvalidCloseCode) {
+			t.Fatalf("CloseWithReason(%d) = %v, want %v", code, err, ErrInvalidCloseCode)
+		}
+	}
+}
+
+func TestReadLoopClosesWithStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *config.WebSocketConfig
+		frame      *ws.Frame
+		wantCode   ws.StatusCode
+		wantReason string
+	}{
+		{
+			name:       "idle timeout",
+			cfg:        &config.WebSocketConfig{IdleTimeout: 20 * time.Millisecond},
+			wantCode:   CloseGoingAway,
+			wantReason: "idle timeout",
+		},
+		{
+			name:       "message too big",
+			cfg:        &config.WebSocketConfig{MaxMessageSize: 4},
+			frame:      ptr(ws.NewTextFrame([]byte("hello world"))),
+			wantCode:   CloseMessageTooBig,
+			wantReason: "message too big",
+		},
+		{
+			name:       "invalid close frame",
+			cfg:        &config.WebSocketConfig{},
+			frame:      ptr(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusNoStatusRcvd, ""))),
+			wantCode:   CloseProtocolError,
+			wantReason: "invalid close frame",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, client := pipeSession(t, tt.cfg)
+			got := readPeerClose(client)
+			done := runReadLoop(s)
+			if tt.frame != nil {
+				writeClientFrame(client, *tt.frame)
+			}
+
+			expectPeerClose(t, got, tt.wantCode, tt.wantReason)
+			if err := <-done; err != nil {
+				t.Fatalf("ReadLoop: %v", err)
+			}
+		})
+	}
+}
+
+func TestSlowConsumerClosesWithPolicyViolation(t *testing.T) {
+	s, client := pipeSession(t, &config.WebSocketConfig{
+		SendQueueSize:  1,
+		OverflowPolicy: OverflowDisconnect,
+	})
+
+	// Клиент не читает, поэтому писатель застревает на первом кадре и
+	// очередь переполняется.
+	var err error
+	for range 3 {
+		if err = s.enqueue(outboundFrame{op: ws.OpText, payload: []byte("event")}); err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrSlowConsumer) {
+		t.Fatalf("enqueue = %v, want %v", err, ErrSlowConsumer)
+	}
+
+	expectPeerClose(t, readPeerClose(client), ClosePolicyViolation, "slow consumer")
+}
+
+func TestReadLoopEchoesClientClose(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      []byte
+		wantCode  ws.StatusCode
+		wantEmpty bool
+	}{
+		{name: "normal", body: ws.NewCloseFrameBody(CloseNormal, "bye"), wantCode: CloseNormal},
+		{name: "going away", body: ws.NewCloseFrameBody(CloseGoingAway, ""), wantCode: CloseGoingAway},
+		{name: "application code", body: ws.NewCloseFrameBody(CloseAuthExpired, "logout"), wantCode: CloseAuthExpired},
+		{name: "without status", wantEmpty: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, client := pipeSession(t, &config.WebSocketConfig{})
+			got := readPeerClose(client)
+			done := runReadLoop(s)
+			writeClientFrame(client, ws.NewCloseFrame(tt.body))
+
+			select {
+			case peer := <-got:
+				switch {
+				case peer.err != nil:
+					t.Fatalf("read close frame: %v", peer.err)
+				case tt.wantEmpty && !peer.empty:
+					t.Fatalf("peer got close %d %q, want an empty close frame", peer.code, peer.reason)
+				case !tt.wantEmpty && (peer.code != tt.wantCode || peer.reason != ""):
+					t.Fatalf("peer got close %d %q, want echoed code %d", peer.code, peer.reason, tt.wantCode)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("peer did not receive a close frame")
+			}
+			if err := <-done; err != nil {
+				t.Fatalf("ReadLoop: %v", err)
+			}
+		})
+	}
+}
+
+func TestCloseSession(t *testing.T) {
+	s, client := pipeSession(t, &config.WebSocketConfig{CloseTimeout: 50 * time.Millisecond})
+	registerSession(s)
+	t.Cleanup(func() { unregisterSession(s.ID.String()) })
+
+	got := readPeerClose(client)
+	if err := CloseSession(context.Background(), s.ID.String(), CloseKicked, "kicked by admin"); err != nil {
+		t.Fatalf("CloseSession: %v", err)
+	}
+	expectPeerClose(t, got, CloseKicked, "kicked by admin")
+
+	if err := CloseSession(context.Background(), uuid.NewString(), CloseKicked, ""); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("CloseSession of unknown session = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
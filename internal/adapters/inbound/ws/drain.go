@@ -0,0 +1,118 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// MessageTypeServerDraining уведомляет клиента о скором закрытии соединения
+// из-за остановки узла.
+const MessageTypeServerDraining = "server_draining"
+
+const (
+	defaultDrainWaveSize     = 100
+	defaultDrainWaveInterval = 200 * time.Millisecond
+	defaultReconnectJitter   = 5 * time.Second
+)
+
+// DrainingPayload подсказывает клиенту, когда переподключаться. Задержка
+// выбирается случайно, чтобы клиенты не пришли на соседние узлы одновременно.
+type DrainingPayload struct {
+	Reason           string `json:"reason"`
+	ReconnectAfterMS int64  `json:"reconnect_after_ms"`
+}
+
+// Drain переводит шлюз в режим остановки: новые upgrade отклоняются с 503,
+// каждая сессия получает server_draining и закрывается с кодом 1001 волнами
+// по DrainWaveSize с паузой DrainWaveInterval. Метод возвращается, когда все
+// обработчики соединений завершились или истёк ctx.
+func (g *Gateway) Drain(ctx context.Context) error {
+	g.mu.Lock()
+	g.draining = true
+	g.mu.Unlock()
+
+	waveSize, interval, jitter := g.drainSettings()
+	sessions := localSessions()
+
+	slog.Info("Draining websocket sessions", slog.Int("sessions", len(sessions)))
+
+	for _, session := range sessions {
+		payload := DrainingPayload{
+			Reason:           "shutdown",
+			ReconnectAfterMS: rand.Int64N(jitter.Milliseconds() + 1),
+		}
+		_ = session.JSON(ctx, MessageTypeServerDraining, payload)
+	}
+
+	for start := 0; start < len(sessions); start += waveSize {
+		if start > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("drain websocket sessions: %w", ctx.Err())
+			case <-time.After(interval):
+			}
+		}
+
+		end := min(start+waveSize, len(sessions))
+		for _, session := range sessions[start:end] {
+			_ = session.CloseWithReason(ctx, CloseGoingAway, "server shutting down")
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		g.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait websocket handlers: %w", ctx.Err())
+	}
+}
+
+// acquire учитывает обработчик соединения. Возвращает false, если шлюз уже
+// останавливается.
+func (g *Gateway) acquire() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.draining {
+		return false
+	}
+	g.handlers.Add(1)
+	return true
+}
+
+func (g *Gateway) isDraining() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.draining
+}
+
+func (g *Gateway) drainSettings() (int, time.Duration, time.Duration) {
+	waveSize, interval, jitter := defaultDrainWaveSize, defaultDrainWaveInterval, defaultReconnectJitter
+	if g.cfg.DrainWaveSize > 0 {
+		waveSize = g.cfg.DrainWaveSize
+	}
+	if g.cfg.DrainWaveInterval > 0 {
+		interval = g.cfg.DrainWaveInterval
+	}
+	if g.cfg.ReconnectJitter > 0 {
+		jitter = g.cfg.ReconnectJitter
+	}
+	return waveSize, interval, jitter
+}
+
+func serviceUnavailable(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	http.Error(w, "server is draining", http.StatusServiceUnavailable)
+}
@@ -0,0 +1,149 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/gobwas/ws"
+	"github.com/google/uuid"
+)
+
+// subjectVerifier принимает любой токен и считает его subject.
+type subjectVerifier struct{}
+
+func (subjectVerifier) Verify(_ context.Context, token string) (domain.Identity, error) {
+	return domain.Identity{Subject: token}, nil
+}
+
+// nopRouter не обрабатывает ни один тип сообщений.
+type nopRouter struct{}
+
+func (nopRouter) Route(context.Context, *Session, Envelope) error { return ErrNoRouteMatched }
+
+// drainedClient — клиент, записывающий кадры сервера до закрывающего.
+// На закрывающий кадр он отвечает своим и закрывает соединение.
+type drainedClient struct {
+	types []string
+	close ws.StatusCode
+	done  chan struct{}
+}
+
+func dialDrainedClient(t *testing.T, url string) *drainedClient {
+	t.Helper()
+
+	conn, _, _, err := ws.Dial(context.Background(), url+"?"+accessTokenParam+"="+uuid.NewString())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	c := &drainedClient{done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		defer conn.Close()
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			frame, err := ws.ReadFrame(conn)
+			if err != nil {
+				return
+			}
+			switch frame.Header.OpCode {
+			case ws.OpText:
+				var env Envelope
+				if err := json.Unmarshal(frame.Payload, &env); err == nil {
+					c.types = append(c.types, env.Type)
+				}
+			case ws.OpClose:
+				c.close, _ = ws.ParseCloseFrameData(frame.Payload)
+				_ = ws.WriteFrame(conn, ws.MaskFrameInPlace(ws.NewCloseFrame(frame.Payload)))
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func waitLocalSessions(t *testing.T, n int) []*Session {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if sessions := localSessions(); len(sessions) == n {
+			return sessions
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("local sessions = %d, want %d", len(localSessions()), n)
+	return nil
+}
+
+func TestDrainClosesEverySession(t *testing.T) {
+	const clients = 5
+
+	cfg := &config.WebSocketConfig{
+		SessionTTL:        time.Minute,
+		CloseTimeout:      time.Second,
+		DrainWaveSize:     2,
+		DrainWaveInterval: 10 * time.Millisecond,
+		ReconnectJitter:   time.Second,
+	}
+	gateway := NewGateway(&GatewayDeps{
+		Registry: NewRegistry(&RegistryDeps{Store: newMemoryRegistry(), NodeID: "node-a", Cfg: cfg}),
+		Router:   nopRouter{},
+		Verifier: subjectVerifier{},
+		Cfg:      cfg,
+	})
+	server := httptest.NewServer(http.HandlerFunc(gateway.HandleWS))
+	t.Cleanup(server.Close)
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	peers := make([]*drainedClient, 0, clients)
+	for range clients {
+		peers = append(peers, dialDrainedClient(t, url))
+	}
+	sessions := waitLocalSessions(t, clients)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := gateway.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+
+	for _, s := range sessions {
+		select {
+		case <-s.writerDone:
+		default:
+			t.Fatalf("session %s is still writing after Drain returned", s.ID)
+		}
+		if _, err := s.conn.Write([]byte{0}); !errors.Is(err, net.ErrClosed) {
+			t.Fatalf("write to session %s after Drain = %v, want %v", s.ID, err, net.ErrClosed)
+		}
+	}
+	if left := localSessions(); len(left) != 0 {
+		t.Fatalf("%d sessions still registered after Drain returned", len(left))
+	}
+
+	for i, peer := range peers {
+		select {
+		case <-peer.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("client %d did not receive a close frame", i)
+		}
+		if len(peer.types) != 1 || peer.types[0] != MessageTypeServerDraining {
+			t.Fatalf("client %d got %v, want [%s]", i, peer.types, MessageTypeServerDraining)
+		}
+		if peer.close != CloseGoingAway {
+			t.Fatalf("client %d close code = %d, want %d", i, peer.close, CloseGoingAway)
+		}
+	}
+}
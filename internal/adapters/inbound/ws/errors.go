@@ -1,5 +1,29 @@
 package ws
 
+import "errors"
+
+var (
+	// ErrSessionClosed возвращается при попытке записи в закрытую сессию.
+	ErrSessionClosed = errors.New("websocket: session is closed")
+	// ErrSendQueueFull означает, что очередь отправки сессии заполнена и кадр
+	// не поставлен в неё.
+	ErrSendQueueFull = errors.New("websocket: send queue is full")
+	// ErrSlowConsumer означает, что сессия закрыта из-за переполнения очереди отправки.
+	ErrSlowConsumer = errors.New("websocket: slow consumer disconnected")
+	// ErrMessageTooBig означает, что сообщение клиента превышает MaxMessageSize.
+	ErrMessageTooBig = errors.New("websocket: message too big")
+	// ErrInvalidCloseCode возвращается для кодов, которые нельзя отправлять в закрывающем кадре.
+	ErrInvalidCloseCode = errors.New("websocket: invalid close code")
+	// ErrUnknownOverflowPolicy возвращается для неизвестной политики переполнения очереди.
+	ErrUnknownOverflowPolicy = errors.New("websocket: unknown overflow policy")
+	// ErrSessionNotFound означает, что сессии нет среди подключённых к узлу.
+	ErrSessionNotFound = errors.New("websocket: session not found")
+	// ErrNodeUnreachable означает, что канал узла-владельца сессии никто не слушает.
+	ErrNodeUnreachable = errors.New("websocket: node is unreachable")
+	// ErrInvalidEventID означает, что идентификатор события не в формате "<ms>-<seq>".
+	ErrInvalidEventID = errors.New("websocket: invalid event id")
+)
+
 // MessageTypeError задаёт тип конверта для сообщений об ошибках.
 const MessageTypeError = "error"
 
@@ -16,3 +40,11 @@ type ErrorPayload struct {
 	Message string `json:"message"`
 	Details string `json:"details,omitempty"`
 }
+
+// ClientError описывает ошибку обработчика, которую безопасно показать клиенту.
+// Session отправляет её в виде конверта error и продолжает чтение сообщений,
+// не разрывая соединение.
+type ClientError interface {
+	error
+	ClientPayload() ErrorPayload
+}
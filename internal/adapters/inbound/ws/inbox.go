@@ -0,0 +1,136 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const inboxPrefix = "inbox:"
+
+// inboxStore — хранилище списков, в которых копятся сообщения офлайн
+// пользователей.
+type inboxStore interface {
+	PushCapped(ctx context.Context, key, value string, maxLen int64, expiration time.Duration) error
+	Range(ctx context.Context, key string) ([]string, error)
+	PopHeadIf(ctx context.Context, key, value string) (bool, error)
+	RemoveValue(ctx context.Context, key, value string) error
+}
+
+// Inbox хранит сообщения пользователей без активных сессий и отдаёт их при
+// следующем подключении. Каждый пользователь получает список не длиннее
+// MaxLen: при переполнении вытесняются самые старые сообщения. Список
+// удаляется, если пользователь не подключался дольше TTL.
+type Inbox struct {
+	store  inboxStore
+	maxLen int64
+	ttl    time.Duration
+}
+
+// InboxDeps агрегирует зависимости почтового ящика.
+type InboxDeps struct {
+	Store  inboxStore
+	MaxLen int64
+	TTL    time.Duration
+}
+
+// NewInbox создаёт почтовый ящик офлайн-сообщений.
+func NewInbox(deps *InboxDeps) *Inbox {
+	if deps == nil || deps.Store == nil {
+		panic("inbox store cannot be nil")
+	}
+
+	return &Inbox{
+		store:  deps.Store,
+		maxLen: deps.MaxLen,
+		ttl:    deps.TTL,
+	}
+}
+
+// Save откладывает для пользователя userID готовый конверт события, поэтому
+// при выдаче клиент получает его в том же виде, что и при доставке в
+// активную сессию.
+func (i *Inbox) Save(ctx context.Context, userID string, data []byte) error {
+	if err := i.store.PushCapped(ctx, inboxPrefix+userID, string(data), i.maxLen, i.ttl); err != nil {
+		return fmt.Errorf("save message to inbox of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// Remove удаляет из ящика пользователя userID сообщение data, если оно там
+// ещё есть.
+func (i *Inbox) Remove(ctx context.Context, userID string, data []byte) error {
+	if err := i.store.RemoveValue(ctx, inboxPrefix+userID, string(data)); err != nil {
+		return fmt.Errorf("remove message from inbox of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// Flush отправляет сессии накопленные сообщения её пользователя в порядке
+// поступления. Каждое сообщение удаляется из ящика, только если после
+// постановки в очередь отправки оно всё ещё первое: элементы, которые уже
+// забрала другая сессия пользователя или вытеснило ограничение MaxLen, не
+// заменяются соседними, ещё не отправленными. Сообщения не вытесняют другие
+// кадры: когда очередь заполнена, Flush ждёт, пока писатель её разберёт, и
+// продолжает со следующей пачки. Если отправка прервалась, оставшиеся
+// сообщения сохраняются до следующего подключения. События, которые сессия
+// уже получила при возобновлении потока, не повторяются.
+func (i *Inbox) Flush(ctx context.Context, s *Session) error {
+	key := inboxPrefix + s.UserID.String()
+
+	total := 0
+	for {
+		entries, err := i.store.Range(ctx, key)
+		if err != nil {
+			return fmt.Errorf("read inbox of %s: %w", s.UserID, err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+
+		var sendErr error
+		sent := 0
+		for _, entry := range entries {
+			if sendErr = i.send(ctx, s, entry); sendErr != nil {
+				break
+			}
+			if _, err := i.store.PopHeadIf(ctx, key, entry); err != nil {
+				return fmt.Errorf("drop flushed inbox entry of %s: %w", s.UserID, err)
+			}
+			sent++
+		}
+		total += sent
+		if sendErr == nil {
+			break
+		}
+		if !errors.Is(sendErr, ErrSendQueueFull) || !s.waitQueueDrained(ctx) {
+			slog.Debug("inbox flush interrupted",
+				slog.String("session_id", s.ID.String()),
+				slog.Int("sent", total),
+				slog.Int("pending", len(entries)-sent),
+				slog.String("error", sendErr.Error()),
+			)
+			return nil
+		}
+	}
+
+	if total > 0 {
+		slog.Debug("inbox flushed",
+			slog.String("session_id", s.ID.String()),
+			slog.Int("sent", total),
+		)
+	}
+	return nil
+}
+
+// send ставит сообщение ящика в очередь отправки, не вытесняя другие кадры.
+func (i *Inbox) send(ctx context.Context, s *Session, entry string) error {
+	var meta struct {
+		EventID string `json:"event_id"`
+	}
+	_ = json.Unmarshal([]byte(entry), &meta)
+	return s.deliverBacklog(ctx, meta.EventID, []byte(entry))
+}
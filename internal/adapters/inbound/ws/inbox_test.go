@@ -0,0 +1,138 @@
+package ws
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// memoryList — ящик одного пользователя в памяти. beforePop вызывается
+// перед каждым PopHeadIf и имитирует параллельные записи в ящик.
+type memoryList struct {
+	entries   []string
+	maxLen    int
+	beforePop func(l *memoryList)
+}
+
+func (l *memoryList) PushCapped(_ context.Context, _, value string, maxLen int64, _ time.Duration) error {
+	l.entries = append(l.entries, value)
+	if maxLen > 0 && len(l.entries) > int(maxLen) {
+		l.entries = l.entries[len(l.entries)-int(maxLen):]
+	}
+	return nil
+}
+
+func (l *memoryList) Range(context.Context, string) ([]string, error) {
+	return slices.Clone(l.entries), nil
+}
+
+func (l *memoryList) PopHeadIf(_ context.Context, _, value string) (bool, error) {
+	if l.beforePop != nil {
+		hook := l.beforePop
+		l.beforePop = nil
+		hook(l)
+	}
+	if len(l.entries) == 0 || l.entries[0] != value {
+		return false, nil
+	}
+	l.entries = l.entries[1:]
+	return true, nil
+}
+
+func (l *memoryList) RemoveValue(_ context.Context, _, value string) error {
+	if i := slices.Index(l.entries, value); i >= 0 {
+		l.entries = slices.Delete(l.entries, i, i+1)
+	}
+	return nil
+}
+
+func TestInboxFlush(t *testing.T) {
+	ctx := context.Background()
+	list := &memoryList{entries: []string{"a", "b", "c"}}
+	inbox := NewInbox(&InboxDeps{Store: list, MaxLen: 3})
+	s := newTestSession(t, 8, OverflowDropOldest)
+
+	if err := inbox.Flush(ctx, s); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := queued(s); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("sent = %v, want [a b c]", got)
+	}
+	if len(list.entries) != 0 {
+		t.Fatalf("inbox = %v, want empty", list.entries)
+	}
+}
+
+func TestInboxFlushKeepsEntriesPushedAtCap(t *testing.T) {
+	ctx := context.Background()
+	list := &memoryList{entries: []string{"a", "b", "c"}}
+	inbox := NewInbox(&InboxDeps{Store: list, MaxLen: 3})
+	// Новое сообщение приходит в полный ящик после чтения и вытесняет a.
+	list.beforePop = func(l *memoryList) {
+		_ = l.PushCapped(ctx, "", "d", 3, 0)
+	}
+	s := newTestSession(t, 8, OverflowDropOldest)
+
+	if err := inbox.Flush(ctx, s); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := queued(s); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Fatalf("sent = %v, want [a b c]", got)
+	}
+	if !slices.Equal(list.entries, []string{"d"}) {
+		t.Fatalf("inbox = %v, want [d]", list.entries)
+	}
+}
+
+func TestInboxFlushWaitsForFullQueue(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	want := []string{"a", "b", "c", "d", "e"}
+	list := &memoryList{entries: slices.Clone(want)}
+	inbox := NewInbox(&InboxDeps{Store: list})
+	s := newTestSession(t, 2, OverflowDropOldest)
+
+	received := make(chan []string)
+	go func() {
+		var got []string
+		for len(got) < len(want) && ctx.Err() == nil {
+			select {
+			case frame := <-s.send:
+				got = append(got, string(frame.payload))
+			case <-ctx.Done():
+			}
+		}
+		received <- got
+	}()
+
+	if err := inbox.Flush(ctx, s); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := <-received; !slices.Equal(got, want) {
+		t.Fatalf("sent = %v, want %v", got, want)
+	}
+	if len(list.entries) != 0 {
+		t.Fatalf("inbox = %v, want empty", list.entries)
+	}
+}
+
+func TestInboxFlushKeepsUnsentEntries(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	list := &memoryList{entries: []string{"a", "b", "c", "d", "e"}}
+	inbox := NewInbox(&InboxDeps{Store: list})
+	s := newTestSession(t, 2, OverflowDropOldest)
+
+	if err := inbox.Flush(ctx, s); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if got := queued(s); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("sent = %v, want [a b]", got)
+	}
+	if !slices.Equal(list.entries, []string{"c", "d", "e"}) {
+		t.Fatalf("inbox = %v, want [c d e]", list.entries)
+	}
+}
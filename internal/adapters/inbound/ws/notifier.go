@@ -5,28 +5,74 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strings"
+	"log/slog"
 )
 
 const sessionPrefix = "session:"
 
-// sessionLookup определяет минимальный интерфейс хранилища, необходимый для доставки сообщений.
+// sessionLookup определяет минимальный интерфейс хранилища, необходимый для
+// доставки сообщений. RemoveMember удаляет ссылки на сессии недоступных узлов.
 type sessionLookup interface {
-	Get(ctx context.Context, key string) (string, error)
+	Members(ctx context.Context, key string) ([]string, error)
+	RemoveMember(ctx context.Context, key, member string) error
 }
 
-// Notifier отправляет payload во все активные сессии пользователя.
+// Notifier отправляет payload во все активные сессии пользователя. Сессии,
+// подключённые к другим экземплярам шлюза, получают сообщение через NodeBus.
+// Если сессий нет, сообщение откладывается в Inbox до подключения
+// пользователя. Каждое событие записывается в EventLog и получает
+// event_id, по которому клиент возобновляет поток после переподключения.
 type Notifier struct {
-	store sessionLookup
+	store  sessionLookup
+	bus    NodeBus
+	inbox  *Inbox
+	events *EventLog
+	nodeID string
 }
 
-// NewNotifier создаёт нотификатор, использующий переданное хранилище сессий.
-func NewNotifier(store sessionLookup) *Notifier {
-	return &Notifier{store: store}
+// NotifierDeps агрегирует зависимости нотификатора. Inbox необязателен:
+// без него сообщения пользователям без сессий отбрасываются. Без Events
+// события доставляются без event_id и не могут быть повторены.
+type NotifierDeps struct {
+	Store  sessionLookup
+	Bus    NodeBus
+	Inbox  *Inbox
+	Events *EventLog
+	NodeID string
 }
 
-// Notify рассылает сообщение по всем WebSocket-сессиям пользователя.
-func (n *Notifier) Notify(ctx context.Context, userID string, messageType string, payload any) error {
+// NewNotifier создаёт нотификатор, использующий переданное хранилище сессий
+// и шину межузловой доставки.
+func NewNotifier(deps *NotifierDeps) *Notifier {
+	if deps == nil || deps.Store == nil {
+		panic("session store cannot be nil")
+	}
+	if deps.NodeID == "" {
+		panic("node id cannot be empty")
+	}
+
+	return &Notifier{
+		store:  deps.Store,
+		bus:    deps.Bus,
+		inbox:  deps.Inbox,
+		events: deps.Events,
+		nodeID: deps.NodeID,
+	}
+}
+
+// Notify рассылает сообщение по всем WebSocket-сессиям пользователя, а
+// если их нет — сохраняет в Inbox. eventKey идентифицирует событие между
+// повторными доставками: событие с тем же ключом записывается в EventLog
+// один раз и получает прежний event_id. Пустой ключ записывает событие
+// заново при каждом вызове. Сессии узлов, которые перестали слушать
+// свой канал, удаляются; если других сессий не было, сообщение тоже
+// сохраняется в Inbox.
+//
+// Ошибка возвращается, только если сообщение не получила ни одна сессия.
+// Сбои доставки в отдельные сессии логируются: эти сессии получат событие
+// при возобновлении потока, а повтор всей доставки продублировал бы его
+// остальным.
+func (n *Notifier) Notify(ctx context.Context, userID, eventKey, messageType string, payload any) error {
 	if n == nil || n.store == nil {
 		return errors.New("notifier is not initialized")
 	}
@@ -34,37 +80,134 @@ func (n *Notifier) Notify(ctx context.Context, userID string, messageType string
 		return errors.New("user id is empty")
 	}
 
+	eventID, data, err := n.envelope(ctx, userID, eventKey, messageType, payload)
+	if err != nil {
+		return err
+	}
+
 	sessions, err := n.fetchSessions(ctx, userID)
 	if err != nil {
 		return err
 	}
+	if len(sessions) == 0 {
+		return n.saveOffline(ctx, userID, eventID, data)
+	}
 
-	var lastErr error
-	for _, sessionID := range sessions {
-		if sessionID == "" {
+	delivered, stale, lastErr := n.deliverAll(ctx, userID, sessions, eventID, data)
+	if delivered > 0 {
+		if lastErr != nil {
+			slog.Warn("message delivered to some sessions only",
+				slog.String("user_id", userID),
+				slog.Int("delivered", delivered),
+				slog.String("error", lastErr.Error()),
+			)
+		}
+		return nil
+	}
+
+	if stale > 0 && stale == len(sessions) {
+		return n.saveOffline(ctx, userID, eventID, data)
+	}
+	return lastErr
+}
+
+// deliverAll доставляет конверт в сессии sessions и возвращает число
+// сессий, получивших его, число сессий недоступных узлов и последнюю
+// ошибку доставки.
+func (n *Notifier) deliverAll(ctx context.Context, userID string, sessions []sessionRef, eventID string, data []byte) (int, int, error) {
+	var (
+		lastErr   error
+		stale     int
+		delivered int
+	)
+	for _, ref := range sessions {
+		if ref.SessionID == "" {
 			continue
 		}
-		if err := SendToSession(ctx, sessionID, messageType, payload); err != nil {
+		if ref.NodeID != "" && ref.NodeID != n.nodeID {
+			if err := n.forward(ctx, userID, ref, eventID, data); err != nil {
+				if errors.Is(err, ErrNodeUnreachable) {
+					stale++
+				}
+				lastErr = err
+				continue
+			}
+			delivered++
+			continue
+		}
+		if err := deliverToSession(ctx, ref.SessionID, eventID, data); err != nil {
 			lastErr = err
+			continue
 		}
+		delivered++
 	}
+	return delivered, stale, lastErr
+}
 
-	return lastErr
+// saveOffline сохраняет конверт в Inbox и перечитывает список сессий:
+// сессия, зарегистрированная между первым чтением и записью в ящик, могла
+// уже выдать его содержимое и не увидит сообщение до следующего
+// подключения. Такой сессии конверт доставляется напрямую и удаляется из
+// ящика; повтор, если Flush тоже успел его отправить, сессия отсекает по
+// event_id.
+func (n *Notifier) saveOffline(ctx context.Context, userID, eventID string, data []byte) error {
+	if n.inbox == nil {
+		return nil
+	}
+	if err := n.inbox.Save(ctx, userID, data); err != nil {
+		return err
+	}
+
+	sessions, err := n.fetchSessions(ctx, userID)
+	if err != nil {
+		slog.Warn("failed to recheck sessions after saving to inbox",
+			slog.String("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+		return nil
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+	if delivered, _, _ := n.deliverAll(ctx, userID, sessions, eventID, data); delivered == 0 {
+		return nil
+	}
+	if err := n.inbox.Remove(ctx, userID, data); err != nil {
+		slog.Warn("failed to remove delivered message from inbox",
+			slog.String("user_id", userID),
+			slog.String("error", err.Error()),
+		)
+	}
+	return nil
 }
 
-func (n *Notifier) fetchSessions(ctx context.Context, userID string) ([]string, error) {
-	key := sessionPrefix + userID
-	value, err := n.store.Get(ctx, key)
+// envelope записывает событие в журнал пользователя и возвращает его
+// идентификатор и конверт для клиента.
+func (n *Notifier) envelope(ctx context.Context, userID, eventKey, messageType string, payload any) (string, []byte, error) {
+	if n.events != nil {
+		return n.events.Append(ctx, userID, eventKey, messageType, payload)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal %s payload: %w", messageType, err)
+	}
+	data, err := json.Marshal(eventEnvelope{Type: messageType, Payload: body})
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal %s envelope: %w", messageType, err)
+	}
+	return "", data, nil
+}
+
+func (n *Notifier) fetchSessions(ctx context.Context, userID string) ([]sessionRef, error) {
+	entries, err := n.store.Members(ctx, sessionPrefix+userID)
 	if err != nil {
-		if strings.Contains(err.Error(), "key not found") {
-			return nil, nil
-		}
 		return nil, fmt.Errorf("get sessions for %s: %w", userID, err)
 	}
 
-	var sessions []string
-	if err := json.Unmarshal([]byte(value), &sessions); err != nil {
-		return nil, fmt.Errorf("decode sessions for %s: %w", userID, err)
+	sessions := make([]sessionRef, 0, len(entries))
+	for _, entry := range entries {
+		sessions = append(sessions, parseSessionRef(entry))
 	}
 	return sessions, nil
 }
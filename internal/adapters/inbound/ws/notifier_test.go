@@ -0,0 +1,251 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySessions — множества сессий пользователей в памяти. late задаёт
+// элементы, которые появляются в множестве только после первого чтения:
+// так имитируется сессия, зарегистрированная во время Notify.
+type memorySessions struct {
+	mu      sync.Mutex
+	members map[string][]string
+	late    map[string][]string
+	reads   map[string]int
+}
+
+func newMemorySessions() *memorySessions {
+	return &memorySessions{
+		members: make(map[string][]string),
+		late:    make(map[string][]string),
+		reads:   make(map[string]int),
+	}
+}
+
+func (m *memorySessions) Members(_ context.Context, key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads[key]++
+	if m.reads[key] > 1 {
+		m.members[key] = append(m.members[key], m.late[key]...)
+		delete(m.late, key)
+	}
+	return slices.Clone(m.members[key]), nil
+}
+
+func (m *memorySessions) RemoveMember(_ context.Context, key, member string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.members[key] = slices.DeleteFunc(m.members[key], func(v string) bool { return v == member })
+	return nil
+}
+
+func (m *memorySessions) list(key string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.members[key])
+}
+
+// memoryBus — шина узлов в памяти: Publish синхронно вызывает обработчик
+// подписчика канала.
+type memoryBus struct {
+	mu       sync.Mutex
+	handlers map[string]func(ctx context.Context, payload []byte)
+	ready    chan struct{}
+}
+
+func newMemoryBus() *memoryBus {
+	return &memoryBus{
+		handlers: make(map[string]func(ctx context.Context, payload []byte)),
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+func (b *memoryBus) Publish(ctx context.Context, channel string, payload []byte) (int64, error) {
+	b.mu.Lock()
+	handler := b.handlers[channel]
+	b.mu.Unlock()
+	if handler == nil {
+		return 0, nil
+	}
+	handler(ctx, payload)
+	return 1, nil
+}
+
+func (b *memoryBus) Subscribe(ctx context.Context, channel string, handler func(ctx context.Context, payload []byte)) error {
+	b.mu.Lock()
+	b.handlers[channel] = handler
+	b.mu.Unlock()
+	b.ready <- struct{}{}
+
+	<-ctx.Done()
+	b.mu.Lock()
+	delete(b.handlers, channel)
+	b.mu.Unlock()
+	return nil
+}
+
+// localTestSession регистрирует тестовую сессию в пуле узла.
+func localTestSession(t *testing.T) *Session {
+	t.Helper()
+
+	s := newTestSession(t, 8, OverflowDropOldest)
+	registerSession(s)
+	t.Cleanup(func() { unregisterSession(s.ID.String()) })
+	return s
+}
+
+// listen запускает ListenRemote узла и ждёт подписки.
+func listen(t *testing.T, n *Notifier, bus *memoryBus) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = n.ListenRemote(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	select {
+	case <-bus.ready:
+	case <-time.After(time.Second):
+		t.Fatal("ListenRemote did not subscribe")
+	}
+}
+
+func payloadOf(t *testing.T, frames []string) []string {
+	t.Helper()
+
+	var payloads []string
+	for _, frame := range frames {
+		var env struct {
+			Payload string `json:"payload"`
+		}
+		if err := json.Unmarshal([]byte(frame), &env); err != nil {
+			t.Fatalf("decode frame %s: %v", frame, err)
+		}
+		payloads = append(payloads, env.Payload)
+	}
+	return payloads
+}
+
+func TestNotifyLocalSession(t *testing.T) {
+	store := newMemorySessions()
+	s := localTestSession(t)
+	userKey := sessionPrefix + s.UserID.String()
+	store.members[userKey] = []string{newSessionRef(s.ID.String(), "node-a").String()}
+
+	n := NewNotifier(&NotifierDeps{Store: store, NodeID: "node-a"})
+	if err := n.Notify(context.Background(), s.UserID.String(), "", "message_delivered", "hi"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := payloadOf(t, queued(s)); !slices.Equal(got, []string{"hi"}) {
+		t.Fatalf("payloads = %v, want [hi]", got)
+	}
+}
+
+func TestNotifyWithoutSessionsSavesToInbox(t *testing.T) {
+	list := &memoryList{}
+	n := NewNotifier(&NotifierDeps{
+		Store:  newMemorySessions(),
+		Inbox:  NewInbox(&InboxDeps{Store: list}),
+		NodeID: "node-a",
+	})
+
+	if err := n.Notify(context.Background(), "user", "", "message_delivered", "hi"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := payloadOf(t, list.entries); !slices.Equal(got, []string{"hi"}) {
+		t.Fatalf("inbox = %v, want [hi]", got)
+	}
+}
+
+func TestNotifyUnreachableNodeSavesToInbox(t *testing.T) {
+	store := newMemorySessions()
+	userKey := sessionPrefix + "user"
+	store.members[userKey] = []string{newSessionRef("gone", "node-b").String()}
+	list := &memoryList{}
+
+	n := NewNotifier(&NotifierDeps{
+		Store:  store,
+		Bus:    newMemoryBus(),
+		Inbox:  NewInbox(&InboxDeps{Store: list}),
+		NodeID: "node-a",
+	})
+	if err := n.Notify(context.Background(), "user", "", "message_delivered", "hi"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := store.list(userKey); len(got) != 0 {
+		t.Fatalf("sessions = %v, want the stale ref removed", got)
+	}
+	if len(list.entries) != 1 {
+		t.Fatalf("inbox = %v, want the message saved", list.entries)
+	}
+}
+
+func TestNotifyForwardsToRemoteNode(t *testing.T) {
+	store := newMemorySessions()
+	bus := newMemoryBus()
+	s := localTestSession(t)
+	userKey := sessionPrefix + s.UserID.String()
+	store.members[userKey] = []string{newSessionRef(s.ID.String(), "node-b").String()}
+
+	listen(t, NewNotifier(&NotifierDeps{Store: store, Bus: bus, NodeID: "node-b"}), bus)
+	sender := NewNotifier(&NotifierDeps{Store: store, Bus: bus, NodeID: "node-a"})
+
+	if err := sender.Notify(context.Background(), s.UserID.String(), "", "message_delivered", "hi"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := payloadOf(t, queued(s)); !slices.Equal(got, []string{"hi"}) {
+		t.Fatalf("payloads = %v, want [hi]", got)
+	}
+}
+
+func TestListenRemotePrunesUnknownSession(t *testing.T) {
+	store := newMemorySessions()
+	bus := newMemoryBus()
+	userKey := sessionPrefix + "user"
+	store.members[userKey] = []string{newSessionRef("closed", "node-b").String()}
+
+	listen(t, NewNotifier(&NotifierDeps{Store: store, Bus: bus, NodeID: "node-b"}), bus)
+	sender := NewNotifier(&NotifierDeps{Store: store, Bus: bus, NodeID: "node-a"})
+
+	if err := sender.Notify(context.Background(), "user", "", "message_delivered", "hi"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := store.list(userKey); len(got) != 0 {
+		t.Fatalf("sessions = %v, want the closed session pruned", got)
+	}
+}
+
+func TestNotifyDeliversToSessionRegisteredDuringSave(t *testing.T) {
+	store := newMemorySessions()
+	s := localTestSession(t)
+	userKey := sessionPrefix + s.UserID.String()
+	store.late[userKey] = []string{newSessionRef(s.ID.String(), "node-a").String()}
+	list := &memoryList{}
+
+	n := NewNotifier(&NotifierDeps{
+		Store:  store,
+		Inbox:  NewInbox(&InboxDeps{Store: list}),
+		NodeID: "node-a",
+	})
+	if err := n.Notify(context.Background(), s.UserID.String(), "", "message_delivered", "hi"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got := payloadOf(t, queued(s)); !slices.Equal(got, []string{"hi"}) {
+		t.Fatalf("payloads = %v, want [hi]", got)
+	}
+	if len(list.entries) != 0 {
+		t.Fatalf("inbox = %v, want the delivered message removed", list.entries)
+	}
+}
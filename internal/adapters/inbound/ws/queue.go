@@ -0,0 +1,270 @@
+package ws
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// Политики обработки переполнения очереди отправки сессии. Список
+// допустимых значений ведёт пакет config.
+const (
+	// OverflowDropOldest вытесняет самый старый кадр из очереди.
+	OverflowDropOldest = config.OverflowDropOldest
+	// OverflowDropNewest отбрасывает новый кадр, очередь не меняется.
+	OverflowDropNewest = config.OverflowDropNewest
+	// OverflowDisconnect закрывает соединение медленного клиента.
+	OverflowDisconnect = config.OverflowDisconnect
+)
+
+const (
+	defaultSendQueueSize = 256
+	defaultWriteTimeout  = 10 * time.Second
+	defaultPongTimeout   = 10 * time.Second
+	defaultIdleTimeout   = 60 * time.Second
+
+	// queueDrainPollInterval — период проверки очереди отправки, пока
+	// накопленные события ждут в ней места.
+	queueDrainPollInterval = 20 * time.Millisecond
+)
+
+// Метрики очередей отправки, публикуемые через expvar (/debug/vars на
+// служебном адресе http.debug-address).
+var (
+	sendQueueDepth          = expvar.NewInt("ws_send_queue_depth")
+	sendQueueDropped        = expvar.NewInt("ws_send_queue_dropped_total")
+	slowConsumerDisconnects = expvar.NewInt("ws_slow_consumer_disconnects_total")
+	deadConnectionCloses    = expvar.NewInt("ws_dead_connection_closes_total")
+)
+
+// outboundFrame — кадр, ожидающий отправки писателем сессии.
+type outboundFrame struct {
+	op      ws.OpCode
+	payload []byte
+}
+
+// enqueue помещает кадр в очередь отправки, применяя политику переполнения.
+// Политики drop_* не считаются ошибкой: кадр отбрасывается и учитывается в
+// метриках. При политике disconnect сессия закрывается и возвращается
+// ErrSlowConsumer.
+func (s *Session) enqueue(frame outboundFrame) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	select {
+	case <-s.done:
+		return ErrSessionClosed
+	default:
+	}
+
+	select {
+	case s.send <- frame:
+		sendQueueDepth.Add(1)
+		return nil
+	default:
+	}
+
+	switch s.policy {
+	case OverflowDropNewest:
+		sendQueueDropped.Add(1)
+		slog.Debug("websocket send queue full, frame dropped",
+			slog.String("session_id", s.ID.String()),
+			slog.String("policy", s.policy),
+		)
+		return nil
+	case OverflowDisconnect:
+		slowConsumerDisconnects.Add(1)
+		slog.Warn("websocket slow consumer disconnected",
+			slog.String("session_id", s.ID.String()),
+			slog.Int("queue_depth", len(s.send)),
+		)
+		go func() { _ = s.closeWithStatus(ClosePolicyViolation, "slow consumer") }()
+		return ErrSlowConsumer
+	default:
+		select {
+		case <-s.send:
+			sendQueueDepth.Add(-1)
+			sendQueueDropped.Add(1)
+		default:
+		}
+		select {
+		case s.send <- frame:
+			sendQueueDepth.Add(1)
+		default:
+			sendQueueDropped.Add(1)
+		}
+		return nil
+	}
+}
+
+// tryEnqueue помещает кадр в очередь, не применяя политику переполнения:
+// при заполненной очереди возвращается ErrSendQueueFull. Так отправляются
+// накопленные события, пропажу которых нужно заметить.
+func (s *Session) tryEnqueue(frame outboundFrame) error {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	select {
+	case <-s.done:
+		return ErrSessionClosed
+	default:
+	}
+
+	select {
+	case s.send <- frame:
+		sendQueueDepth.Add(1)
+		return nil
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// waitQueueDrained ждёт, пока писатель разберёт очередь отправки.
+// Возвращает false, если сессия закрылась или контекст отменён.
+func (s *Session) waitQueueDrained(ctx context.Context) bool {
+	ticker := time.NewTicker(queueDrainPollInterval)
+	defer ticker.Stop()
+
+	for len(s.send) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-s.done:
+			return false
+		case <-ticker.C:
+		}
+	}
+	return true
+}
+
+// QueueDepth возвращает число кадров, ожидающих отправки.
+func (s *Session) QueueDepth() int {
+	return len(s.send)
+}
+
+// writeLoop — единственная горутина, пишущая в соединение. Помимо кадров из
+// очереди она отправляет серверные ping-и. Завершается при закрытии сессии
+// или при ошибке записи. Если задан закрывающий кадр, перед ним отправляются
+// кадры, уже стоящие в очереди, например server_draining.
+func (s *Session) writeLoop() {
+	defer close(s.writerDone)
+	defer s.discardQueue()
+
+	var ping <-chan time.Time
+	if s.pingInterval > 0 {
+		ticker := time.NewTicker(s.pingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-s.done:
+			if s.closeFrame != nil {
+				s.flushQueue()
+				_ = s.writeFrame(*s.closeFrame)
+			}
+			return
+		case frame := <-s.send:
+			sendQueueDepth.Add(-1)
+			if err := s.writeFrame(frame); err != nil {
+				s.abortWrite(err)
+				return
+			}
+		case <-ping:
+			if err := s.ping(); err != nil {
+				s.abortWrite(err)
+				return
+			}
+		}
+	}
+}
+
+// ping отправляет клиенту ping и сокращает дедлайн чтения до PongTimeout:
+// если за это время от клиента не придёт ни одного кадра, ReadLoop
+// завершится по таймауту.
+func (s *Session) ping() error {
+	if err := s.writeFrame(outboundFrame{op: ws.OpPing}); err != nil {
+		return err
+	}
+	if s.pongTimeout <= 0 {
+		return nil
+	}
+	if err := s.conn.SetReadDeadline(time.Now().Add(s.pongTimeout)); err != nil {
+		return fmt.Errorf("set read deadline: %w", err)
+	}
+	return nil
+}
+
+// abortWrite закрывает соединение после ошибки записи. Это прерывает
+// ReadLoop, после чего шлюз удаляет сессию штатным образом.
+func (s *Session) abortWrite(err error) {
+	slog.Debug("websocket write failed",
+		slog.String("session_id", s.ID.String()),
+		slog.String("error", err.Error()),
+	)
+	_ = s.conn.Close()
+}
+
+// flushQueue отправляет кадры, оставшиеся в очереди закрываемой сессии.
+// На всю очередь отводится один WriteTimeout, чтобы медленный клиент не
+// задерживал закрытие.
+func (s *Session) flushQueue() {
+	deadline := time.Now().Add(s.writeTimeout)
+	for {
+		select {
+		case frame := <-s.send:
+			sendQueueDepth.Add(-1)
+			if err := s.writeFrameBefore(frame, deadline); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *Session) writeFrame(frame outboundFrame) error {
+	return s.writeFrameBefore(frame, time.Now().Add(s.writeTimeout))
+}
+
+func (s *Session) writeFrameBefore(frame outboundFrame, deadline time.Time) error {
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return fmt.Errorf("set write deadline: %w", err)
+	}
+	if err := wsutil.WriteServerMessage(s.conn, frame.op, frame.payload); err != nil {
+		return fmt.Errorf("write server message: %w", err)
+	}
+	return nil
+}
+
+// discardQueue освобождает очередь закрытой сессии и корректирует метрики.
+func (s *Session) discardQueue() {
+	for {
+		select {
+		case <-s.send:
+			sendQueueDepth.Add(-1)
+		default:
+			return
+		}
+	}
+}
+
+// WriteMessage ставит сообщение в очередь отправки клиенту. Запись в сокет
+// выполняет writeLoop, поэтому кадры разных отправителей не перемешиваются,
+// а медленный клиент не блокирует вызывающую сторону.
+func (s *Session) WriteMessage(ctx context.Context, op ws.OpCode, payload []byte) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	return s.enqueue(outboundFrame{op: op, payload: payload})
+}
@@ -0,0 +1,103 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+)
+
+func TestEnqueueOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy    string
+		want      []string
+		wantErr   error
+		wantClose bool
+	}{
+		{policy: OverflowDropOldest, want: []string{"2", "3"}},
+		{policy: OverflowDropNewest, want: []string{"1", "2"}},
+		{policy: OverflowDisconnect, want: []string{"1", "2"}, wantErr: ErrSlowConsumer, wantClose: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s := newTestSession(t, 2, tt.policy)
+
+			var err error
+			for _, payload := range []string{"1", "2", "3"} {
+				err = s.enqueue(outboundFrame{op: ws.OpText, payload: []byte(payload)})
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("enqueue on full queue = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantClose {
+				select {
+				case <-s.done:
+				case <-time.After(time.Second):
+					t.Fatal("slow consumer session was not closed")
+				}
+			}
+			if got := queued(s); !slices.Equal(got, tt.want) {
+				t.Fatalf("queue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTryEnqueueIgnoresOverflowPolicy(t *testing.T) {
+	s := newTestSession(t, 1, OverflowDropOldest)
+
+	if err := s.tryEnqueue(outboundFrame{op: ws.OpText, payload: []byte("1")}); err != nil {
+		t.Fatalf("tryEnqueue: %v", err)
+	}
+	if err := s.tryEnqueue(outboundFrame{op: ws.OpText, payload: []byte("2")}); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("tryEnqueue on full queue = %v, want %v", err, ErrSendQueueFull)
+	}
+	if got := queued(s); !slices.Equal(got, []string{"1"}) {
+		t.Fatalf("queue = %v, want [1]", got)
+	}
+}
+
+func TestPongBypassesOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		queued []string
+		want   []string
+	}{
+		{name: "free queue", policy: OverflowDropOldest, want: []string{"ping"}},
+		{name: "full queue, drop_oldest", policy: OverflowDropOldest, queued: []string{"event"}, want: []string{"event"}},
+		{name: "full queue, drop_newest", policy: OverflowDropNewest, queued: []string{"event"}, want: []string{"event"}},
+		{name: "full queue, disconnect", policy: OverflowDisconnect, queued: []string{"event"}, want: []string{"event"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSession(t, 1, tt.policy)
+			for _, payload := range tt.queued {
+				if err := s.enqueue(outboundFrame{op: ws.OpText, payload: []byte(payload)}); err != nil {
+					t.Fatalf("enqueue: %v", err)
+				}
+			}
+
+			ping := ws.Header{Fin: true, OpCode: ws.OpPing, Length: 4}
+			if err := s.handleControl(context.Background(), ping, strings.NewReader("ping")); err != nil {
+				t.Fatalf("handle ping: %v", err)
+			}
+
+			select {
+			case <-s.done:
+				t.Fatal("pong on a full queue closed the session")
+			default:
+			}
+			if got := queued(s); !slices.Equal(got, tt.want) {
+				t.Fatalf("queue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+)
+
+const (
+	// nodesKey хранит множество всех известных узлов шлюза.
+	nodesKey = "gateway:nodes"
+	// nodeAlivePrefix — префикс ключа-маркера жизни узла с TTL.
+	nodeAlivePrefix = "node-alive:"
+	// nodeSessionsPrefix — префикс индекса сессий узла: элементы "<user>/<session>".
+	nodeSessionsPrefix = "node-sessions:"
+	// nodeSessionSeparator разделяет пользователя и сессию в индексе узла.
+	nodeSessionSeparator = "/"
+)
+
+// Registry регистрирует сессии текущего узла в общем хранилище. Записи
+// сессий имеют TTL и продлеваются heartbeat-ом, пока соединение живо.
+// Sweep удаляет записи узлов, переставших отправлять heartbeat.
+//
+// Схема ключей:
+//
+//	session:<user>         — множество "<session>@<node>" с TTL на элемент;
+//	node-sessions:<node>   — индекс сессий узла "<user>/<session>";
+//	node-alive:<node>      — маркер жизни узла с TTL;
+//	gateway:nodes          — множество известных узлов.
+type Registry struct {
+	store  SessionStore
+	nodeID string
+	cfg    *config.WebSocketConfig
+}
+
+// RegistryDeps агрегирует зависимости реестра сессий.
+type RegistryDeps struct {
+	Store  SessionStore
+	NodeID string
+	Cfg    *config.WebSocketConfig
+}
+
+// NewRegistry создаёт реестр сессий узла NodeID.
+func NewRegistry(deps *RegistryDeps) *Registry {
+	if deps == nil || deps.Store == nil {
+		panic("session store cannot be nil")
+	}
+	if deps.NodeID == "" {
+		panic("node id cannot be empty")
+	}
+	if deps.Cfg == nil {
+		panic("websocket config cannot be nil")
+	}
+
+	return &Registry{
+		store:  deps.Store,
+		nodeID: deps.NodeID,
+		cfg:    deps.Cfg,
+	}
+}
+
+// NodeID возвращает идентификатор узла, которому принадлежит реестр.
+func (r *Registry) NodeID() string { return r.nodeID }
+
+// Register записывает сессию в множество сессий пользователя и индекс узла.
+func (r *Registry) Register(ctx context.Context, session *Session) error {
+	userKey := sessionPrefix + session.UserID.String()
+	if err := r.store.AddMember(ctx, userKey, r.sessionRef(session), r.cfg.SessionTTL); err != nil {
+		return fmt.Errorf("add session to %s: %w", userKey, err)
+	}
+
+	indexKey := nodeSessionsPrefix + r.nodeID
+	if err := r.store.AddMember(ctx, indexKey, nodeSessionEntry(session), 0); err != nil {
+		return fmt.Errorf("add session to %s: %w", indexKey, err)
+	}
+	return nil
+}
+
+// Unregister удаляет сессию из множества пользователя и индекса узла.
+func (r *Registry) Unregister(ctx context.Context, session *Session) error {
+	userKey := sessionPrefix + session.UserID.String()
+	if err := r.store.RemoveMember(ctx, userKey, r.sessionRef(session)); err != nil {
+		return fmt.Errorf("remove session from %s: %w", userKey, err)
+	}
+
+	indexKey := nodeSessionsPrefix + r.nodeID
+	if err := r.store.RemoveMember(ctx, indexKey, nodeSessionEntry(session)); err != nil {
+		return fmt.Errorf("remove session from %s: %w", indexKey, err)
+	}
+	return nil
+}
+
+// Heartbeat продлевает маркер жизни узла и TTL всех локальных сессий.
+func (r *Registry) Heartbeat(ctx context.Context) error {
+	if err := r.store.AddMember(ctx, nodesKey, r.nodeID, 0); err != nil {
+		return fmt.Errorf("register node %s: %w", r.nodeID, err)
+	}
+	if err := r.store.Add(ctx, nodeAlivePrefix+r.nodeID, time.Now().Unix(), r.cfg.SessionTTL); err != nil {
+		return fmt.Errorf("refresh node %s liveness: %w", r.nodeID, err)
+	}
+
+	var errs []error
+	for _, session := range localSessions() {
+		userKey := sessionPrefix + session.UserID.String()
+		if err := r.store.AddMember(ctx, userKey, r.sessionRef(session), r.cfg.SessionTTL); err != nil {
+			errs = append(errs, fmt.Errorf("refresh session %s: %w", session.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sweep находит узлы без действующего маркера жизни и удаляет их сессии.
+// Операция идемпотентна, поэтому её могут параллельно выполнять все узлы.
+func (r *Registry) Sweep(ctx context.Context) error {
+	nodes, err := r.store.Members(ctx, nodesKey)
+	if err != nil {
+		return fmt.Errorf("list nodes: %w", err)
+	}
+
+	var errs []error
+	for _, nodeID := range nodes {
+		if nodeID == r.nodeID {
+			continue
+		}
+
+		_, err := r.store.Get(ctx, nodeAlivePrefix+nodeID)
+		if err == nil {
+			continue
+		}
+		if !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("check node %s liveness: %w", nodeID, err))
+			continue
+		}
+
+		slog.Info("Sweeping sessions of dead gateway node", slog.String("node_id", nodeID))
+		if err := r.sweepNode(ctx, nodeID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Leave удаляет все сессии текущего узла и сам узел из реестра. Вызывается
+// при остановке приложения.
+func (r *Registry) Leave(ctx context.Context) error {
+	if err := r.sweepNode(ctx, r.nodeID); err != nil {
+		return err
+	}
+	if err := r.store.Remove(ctx, nodeAlivePrefix+r.nodeID); err != nil {
+		return fmt.Errorf("remove node %s liveness: %w", r.nodeID, err)
+	}
+	return nil
+}
+
+// Run выполняет heartbeat и sweep по таймерам из конфигурации до отмены
+// контекста. Первый heartbeat выполняется сразу.
+func (r *Registry) Run(ctx context.Context) {
+	if err := r.Heartbeat(ctx); err != nil {
+		slog.Warn("gateway heartbeat failed", slog.String("error", err.Error()))
+	}
+
+	heartbeat := time.NewTicker(r.cfg.HeartbeatInterval)
+	defer heartbeat.Stop()
+	sweep := time.NewTicker(r.cfg.SweepInterval)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			if err := r.Heartbeat(ctx); err != nil {
+				slog.Warn("gateway heartbeat failed", slog.String("error", err.Error()))
+			}
+		case <-sweep.C:
+			if err := r.Sweep(ctx); err != nil {
+				slog.Warn("gateway sweep failed", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func (r *Registry) sweepNode(ctx context.Context, nodeID string) error {
+	indexKey := nodeSessionsPrefix + nodeID
+	entries, err := r.store.Members(ctx, indexKey)
+	if err != nil {
+		return fmt.Errorf("list sessions of node %s: %w", nodeID, err)
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		userID, sessionID, ok := strings.Cut(entry, nodeSessionSeparator)
+		if !ok {
+			continue
+		}
+		ref := newSessionRef(sessionID, nodeID).String()
+		if err := r.store.RemoveMember(ctx, sessionPrefix+userID, ref); err != nil {
+			errs = append(errs, fmt.Errorf("remove session %s: %w", ref, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if err := r.store.Remove(ctx, indexKey); err != nil {
+		return fmt.Errorf("remove index %s: %w", indexKey, err)
+	}
+	if err := r.store.RemoveMember(ctx, nodesKey, nodeID); err != nil {
+		return fmt.Errorf("remove node %s: %w", nodeID, err)
+	}
+	return nil
+}
+
+func (r *Registry) sessionRef(session *Session) string {
+	return newSessionRef(session.ID.String(), r.nodeID).String()
+}
+
+func nodeSessionEntry(session *Session) string {
+	return session.UserID.String() + nodeSessionSeparator + session.ID.String()
+}
+
+func isNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "key not found")
+}
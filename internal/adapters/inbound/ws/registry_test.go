@@ -0,0 +1,173 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+)
+
+// memoryRegistry — хранилище реестра в памяти: значения и множества.
+// Сроки жизни не учитываются.
+type memoryRegistry struct {
+	mu     sync.Mutex
+	values map[string]string
+	sets   map[string][]string
+}
+
+func newMemoryRegistry() *memoryRegistry {
+	return &memoryRegistry{
+		values: make(map[string]string),
+		sets:   make(map[string][]string),
+	}
+}
+
+func (m *memoryRegistry) Add(_ context.Context, key string, value any, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = fmt.Sprint(value)
+	return nil
+}
+
+func (m *memoryRegistry) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	if !ok {
+		return "", fmt.Errorf("key not found: %s", key)
+	}
+	return value, nil
+}
+
+func (m *memoryRegistry) Remove(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.values, key)
+		delete(m.sets, key)
+	}
+	return nil
+}
+
+func (m *memoryRegistry) AddMember(_ context.Context, key, member string, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !slices.Contains(m.sets[key], member) {
+		m.sets[key] = append(m.sets[key], member)
+	}
+	return nil
+}
+
+func (m *memoryRegistry) RemoveMember(_ context.Context, key, member string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sets[key] = slices.DeleteFunc(m.sets[key], func(v string) bool { return v == member })
+	return nil
+}
+
+func (m *memoryRegistry) Members(_ context.Context, key string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := slices.Clone(m.sets[key])
+	slices.Sort(members)
+	return members, nil
+}
+
+func newTestRegistry(store SessionStore, nodeID string) *Registry {
+	return NewRegistry(&RegistryDeps{
+		Store:  store,
+		NodeID: nodeID,
+		Cfg:    &config.WebSocketConfig{SessionTTL: time.Minute},
+	})
+}
+
+func sortedRefs(nodeID string, sessions ...*Session) []string {
+	refs := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		refs = append(refs, newSessionRef(s.ID.String(), nodeID).String())
+	}
+	slices.Sort(refs)
+	return refs
+}
+
+func TestRegistryKeepsParallelSessionsOfUser(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryRegistry()
+	registry := newTestRegistry(store, "node-a")
+
+	first := newTestSession(t, 1, OverflowDropOldest)
+	second := newTestSession(t, 1, OverflowDropOldest)
+	second.UserID = first.UserID
+	userKey := sessionPrefix + first.UserID.String()
+
+	for _, s := range []*Session{first, second} {
+		if err := registry.Register(ctx, s); err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	}
+	if got, _ := store.Members(ctx, userKey); !slices.Equal(got, sortedRefs("node-a", first, second)) {
+		t.Fatalf("sessions = %v, want both sessions", got)
+	}
+
+	if err := registry.Unregister(ctx, first); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	if got, _ := store.Members(ctx, userKey); !slices.Equal(got, sortedRefs("node-a", second)) {
+		t.Fatalf("sessions = %v, want only the second session", got)
+	}
+	if got, _ := store.Members(ctx, nodeSessionsPrefix+"node-a"); !slices.Equal(got, []string{nodeSessionEntry(second)}) {
+		t.Fatalf("node index = %v, want only the second session", got)
+	}
+}
+
+func TestRegistrySweepRemovesSessionsOfDeadNode(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryRegistry()
+	alive := newTestRegistry(store, "node-a")
+	dead := newTestRegistry(store, "node-b")
+
+	local := newTestSession(t, 1, OverflowDropOldest)
+	remote := newTestSession(t, 1, OverflowDropOldest)
+	remote.UserID = local.UserID
+	userKey := sessionPrefix + local.UserID.String()
+
+	if err := alive.Register(ctx, local); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := dead.Register(ctx, remote); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	for _, r := range []*Registry{alive, dead} {
+		if err := r.Heartbeat(ctx); err != nil {
+			t.Fatalf("Heartbeat: %v", err)
+		}
+	}
+
+	if err := alive.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if got, _ := store.Members(ctx, userKey); len(got) != 2 {
+		t.Fatalf("sessions = %v, want both sessions while node-b is alive", got)
+	}
+
+	// Маркер жизни node-b истёк.
+	if err := store.Remove(ctx, nodeAlivePrefix+"node-b"); err != nil {
+		t.Fatalf("expire node-b: %v", err)
+	}
+	if err := alive.Sweep(ctx); err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if got, _ := store.Members(ctx, userKey); !slices.Equal(got, sortedRefs("node-a", local)) {
+		t.Fatalf("sessions = %v, want only the session of node-a", got)
+	}
+	if got, _ := store.Members(ctx, nodeSessionsPrefix+"node-b"); len(got) != 0 {
+		t.Fatalf("node-b index = %v, want removed", got)
+	}
+	if got, _ := store.Members(ctx, nodesKey); !slices.Equal(got, []string{"node-a"}) {
+		t.Fatalf("nodes = %v, want [node-a]", got)
+	}
+}
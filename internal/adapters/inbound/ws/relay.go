@@ -0,0 +1,166 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const (
+	// nodeChannelPrefix — префикс канала, через который узел принимает доставки
+	// для своих сессий от других экземпляров шлюза.
+	nodeChannelPrefix = "gateway:node:"
+	// sessionRefSeparator разделяет идентификаторы сессии и узла в записи реестра.
+	sessionRefSeparator = "@"
+)
+
+// NodeBus описывает канал обмена сообщениями между экземплярами шлюза.
+// Типичная реализация использует Redis pub/sub. Publish возвращает число
+// подписчиков, получивших сообщение.
+type NodeBus interface {
+	Publish(ctx context.Context, channel string, payload []byte) (int64, error)
+	Subscribe(ctx context.Context, channel string, handler func(ctx context.Context, payload []byte)) error
+}
+
+// sessionRef указывает на сессию и узел, к которому она подключена.
+type sessionRef struct {
+	SessionID string
+	NodeID    string
+}
+
+// newSessionRef возвращает ссылку на сессию, принадлежащую узлу nodeID.
+func newSessionRef(sessionID, nodeID string) sessionRef {
+	return sessionRef{SessionID: sessionID, NodeID: nodeID}
+}
+
+// parseSessionRef разбирает запись реестра вида "<session>@<node>". Записи без
+// узла считаются локальными.
+func parseSessionRef(value string) sessionRef {
+	sessionID, nodeID, _ := strings.Cut(value, sessionRefSeparator)
+	return sessionRef{SessionID: sessionID, NodeID: nodeID}
+}
+
+func (r sessionRef) String() string {
+	if r.NodeID == "" {
+		return r.SessionID
+	}
+	return r.SessionID + sessionRefSeparator + r.NodeID
+}
+
+// remoteDelivery — сообщение, которое узел пересылает владельцу сессии.
+// Data содержит готовый конверт события, UserID — владельца сессии: по нему
+// узел удаляет ссылку на сессию, которой у него уже нет.
+type remoteDelivery struct {
+	UserID    string          `json:"user_id"`
+	SessionID string          `json:"session_id"`
+	EventID   string          `json:"event_id,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func nodeChannel(nodeID string) string {
+	return nodeChannelPrefix + nodeID
+}
+
+// forward публикует доставку в канал узла, владеющего сессией. Если канал
+// никто не слушает, узел считается недоступным: ссылка на сессию удаляется
+// из множества пользователя и возвращается ErrNodeUnreachable.
+//
+// Доставка на живой узел — best-effort: узел не подтверждает её, и если
+// сессия там уже закрыта, событие считается доставленным и не попадает в
+// Inbox. Клиент получит его из EventLog при возобновлении потока, а узел
+// удалит ссылку на закрытую сессию (см. ListenRemote), и следующие события
+// пойдут в другие сессии или в Inbox.
+func (n *Notifier) forward(ctx context.Context, userID string, ref sessionRef, eventID string, envelope []byte) error {
+	if n.bus == nil {
+		return fmt.Errorf("session %s belongs to node %s: node bus is not configured", ref.SessionID, ref.NodeID)
+	}
+
+	data, err := json.Marshal(remoteDelivery{
+		UserID:    userID,
+		SessionID: ref.SessionID,
+		EventID:   eventID,
+		Data:      envelope,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal remote delivery: %w", err)
+	}
+
+	receivers, err := n.bus.Publish(ctx, nodeChannel(ref.NodeID), data)
+	if err != nil {
+		return fmt.Errorf("forward to node %s: %w", ref.NodeID, err)
+	}
+	if receivers == 0 {
+		unreachable := fmt.Errorf("%w: %s", ErrNodeUnreachable, ref.NodeID)
+		if err := n.store.RemoveMember(ctx, sessionPrefix+userID, ref.String()); err != nil {
+			return errors.Join(unreachable, fmt.Errorf("remove stale session %s: %w", ref.SessionID, err))
+		}
+		return unreachable
+	}
+	return nil
+}
+
+// ListenRemote подписывается на канал текущего узла и доставляет сообщения,
+// пересланные другими экземплярами, в локальные сессии. Если сессии на узле
+// уже нет, её ссылка удаляется из множества сессий пользователя, чтобы
+// другие узлы перестали пересылать ей события. Блокируется до отмены
+// контекста.
+func (n *Notifier) ListenRemote(ctx context.Context) error {
+	if n == nil || n.bus == nil {
+		return errors.New("notifier node bus is not configured")
+	}
+
+	err := n.bus.Subscribe(ctx, nodeChannel(n.nodeID), func(ctx context.Context, payload []byte) {
+		var delivery remoteDelivery
+		if err := json.Unmarshal(payload, &delivery); err != nil {
+			slog.Warn("failed to decode remote delivery",
+				slog.String("node_id", n.nodeID),
+				slog.String("error", err.Error()),
+			)
+			return
+		}
+		err := delivery.deliver(ctx)
+		if errors.Is(err, ErrSessionNotFound) {
+			n.pruneSession(ctx, delivery)
+			return
+		}
+		if err != nil {
+			slog.Warn("failed to deliver remote message",
+				slog.String("node_id", n.nodeID),
+				slog.String("session_id", delivery.SessionID),
+				slog.String("error", err.Error()),
+			)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("subscribe node channel %s: %w", n.nodeID, err)
+	}
+	return nil
+}
+
+func (d remoteDelivery) deliver(ctx context.Context) error {
+	return deliverToSession(ctx, d.SessionID, d.EventID, d.Data)
+}
+
+// pruneSession удаляет ссылку на сессию текущего узла, которой на нём уже
+// нет.
+func (n *Notifier) pruneSession(ctx context.Context, d remoteDelivery) {
+	if d.UserID == "" {
+		return
+	}
+	ref := newSessionRef(d.SessionID, n.nodeID)
+	if err := n.store.RemoveMember(ctx, sessionPrefix+d.UserID, ref.String()); err != nil {
+		slog.Warn("failed to remove stale session",
+			slog.String("node_id", n.nodeID),
+			slog.String("session_id", d.SessionID),
+			slog.String("error", err.Error()),
+		)
+		return
+	}
+	slog.Debug("stale session removed",
+		slog.String("node_id", n.nodeID),
+		slog.String("session_id", d.SessionID),
+	)
+}
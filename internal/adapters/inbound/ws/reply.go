@@ -0,0 +1,39 @@
+package ws
+
+import "context"
+
+// Типы конвертов, которыми шлюз подтверждает обработку запроса клиента.
+const (
+	// MessageTypeAck подтверждает, что запрос принят и обработан.
+	MessageTypeAck = "ack"
+	// MessageTypeNack сообщает, что запрос принят, но его обработка не удалась.
+	MessageTypeNack = "nack"
+)
+
+// maxRequestIDLength ограничивает длину клиентского идентификатора запроса.
+const maxRequestIDLength = 128
+
+// AckPayload описывает результат успешной обработки запроса: идентификатор,
+// присвоенный сервером, и итоговый статус, например "published".
+type AckPayload struct {
+	MessageID string `json:"message_id,omitempty"`
+	Status    string `json:"status"`
+}
+
+// NackPayload описывает неудачную обработку запроса. MessageID заполняется,
+// если сервер успел присвоить идентификатор до сбоя.
+type NackPayload struct {
+	MessageID string       `json:"message_id,omitempty"`
+	Status    string       `json:"status"`
+	Error     ErrorPayload `json:"error"`
+}
+
+// Ack отправляет клиенту подтверждение запроса с идентификатором requestID.
+func (s *Session) Ack(ctx context.Context, requestID string, payload AckPayload) error {
+	return s.Reply(ctx, requestID, MessageTypeAck, payload)
+}
+
+// Nack отправляет клиенту отказ по запросу с идентификатором requestID.
+func (s *Session) Nack(ctx context.Context, requestID string, payload NackPayload) error {
+	return s.Reply(ctx, requestID, MessageTypeNack, payload)
+}
@@ -0,0 +1,239 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/gobwas/ws"
+)
+
+// Типы конвертов возобновления потока событий.
+const (
+	// MessageTypeResume — запрос клиента на получение событий, пропущенных
+	// после last_event_id.
+	MessageTypeResume = "resume"
+	// MessageTypeResumed подтверждает, что пропущенные события отправлены.
+	MessageTypeResumed = "resumed"
+	// MessageTypeResyncRequired сообщает, что часть пропущенных событий уже
+	// удалена и клиенту нужно заново загрузить состояние.
+	MessageTypeResyncRequired = "resync_required"
+)
+
+// ResumeQueryParam — параметр запроса upgrade с идентификатором последнего
+// полученного клиентом события.
+const ResumeQueryParam = "last_event_id"
+
+// maxSentEvents ограничивает память сессии под идентификаторы отправленных
+// событий. После переполнения возможны повторы, но не пропуски: клиент
+// отсекает их по event_id.
+const maxSentEvents = 1024
+
+// ResumePayload — тело конверта resume.
+type ResumePayload struct {
+	LastEventID string `json:"last_event_id"`
+}
+
+// ResumedPayload — тело ответа resumed.
+type ResumedPayload struct {
+	Replayed int `json:"replayed"`
+}
+
+// ResyncPayload — тело ответа resync_required.
+type ResyncPayload struct {
+	LastEventID string `json:"last_event_id"`
+}
+
+// deliver отправляет клиенту конверт живого события eventID. Пока сессия
+// получает пропущенные и офлайн-события, живые откладываются и
+// отправляются после них. Отложенных событий не больше размера очереди
+// отправки: при переполнении они отбрасываются вместе с последующими, а
+// releaseHeld вместо них отправляет resync_required. Событие, уже
+// отправленное этой сессии, повторно не отправляется.
+func (s *Session) deliver(ctx context.Context, eventID string, data []byte) error {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+
+	if s.replaying {
+		if s.heldOverflow {
+			return nil
+		}
+		if len(s.held) >= cap(s.send) {
+			slog.Warn("too many held events, client must resync",
+				slog.String("session_id", s.ID.String()),
+				slog.Int("held", len(s.held)),
+			)
+			s.held = nil
+			s.heldOverflow = true
+			return nil
+		}
+		s.held = append(s.held, events.StreamEntry{ID: eventID, Data: data})
+		return nil
+	}
+	return s.sendEventLocked(ctx, eventID, data)
+}
+
+// deliverBacklog отправляет накопленное событие в обход отложенных живых.
+// Кадр не вытесняет другие: если очередь отправки заполнена, возвращается
+// ErrSendQueueFull и событие не считается отправленным.
+func (s *Session) deliverBacklog(ctx context.Context, eventID string, data []byte) error {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+
+	if s.wasSent(eventID) {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.tryEnqueue(outboundFrame{op: ws.OpText, payload: data}); err != nil {
+		return err
+	}
+	s.markSent(eventID)
+	return nil
+}
+
+// sendBacklog отправляет накопленное событие, дожидаясь места в очереди
+// отправки, пока писатель её разбирает. Так пропущенные и отложенные
+// события не вытесняются политикой переполнения и не разрывают сессию.
+func (s *Session) sendBacklog(ctx context.Context, eventID string, data []byte) error {
+	for {
+		err := s.deliverBacklog(ctx, eventID, data)
+		if !errors.Is(err, ErrSendQueueFull) {
+			return err
+		}
+		if !s.waitQueueDrained(ctx) {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return ErrSessionClosed
+		}
+	}
+}
+
+func (s *Session) sendEventLocked(ctx context.Context, eventID string, data []byte) error {
+	if s.wasSent(eventID) {
+		return nil
+	}
+	if err := s.WriteMessage(ctx, ws.OpText, data); err != nil {
+		return err
+	}
+	s.markSent(eventID)
+	return nil
+}
+
+func (s *Session) wasSent(eventID string) bool {
+	if eventID == "" {
+		return false
+	}
+	_, ok := s.sent[eventID]
+	return ok
+}
+
+func (s *Session) markSent(eventID string) {
+	if eventID == "" {
+		return
+	}
+	if len(s.sent) >= maxSentEvents {
+		clear(s.sent)
+	}
+	s.sent[eventID] = struct{}{}
+}
+
+// Resume отправляет клиенту события, записанные после lastEventID, и только
+// затем — события, пришедшие во время возобновления. Если разрыв уже не
+// восстановить, клиент получает resync_required. Ответ связывается с
+// запросом requestID.
+func (s *Session) Resume(ctx context.Context, requestID, lastEventID string) error {
+	s.holdEvents()
+
+	err := s.replay(ctx, requestID, lastEventID)
+	return errors.Join(err, s.releaseHeld(ctx, requestID, lastEventID))
+}
+
+// replay отправляет события, записанные после lastEventID, не вытесняя их
+// из очереди отправки. Живые события к этому моменту должны быть отложены
+// holdEvents.
+func (s *Session) replay(ctx context.Context, requestID, lastEventID string) error {
+	if s.eventLog == nil {
+		return s.ReplyError(ctx, requestID, ErrorCodeRouteNotFound, "resume is not supported", "")
+	}
+	if _, ok := parseEventID(lastEventID); !ok {
+		return s.ReplyError(ctx, requestID, ErrorCodeInvalidEnvelope, "invalid last_event_id", "")
+	}
+
+	entries, resync, err := s.eventLog.Since(ctx, s.UserID.String(), lastEventID)
+	if err != nil {
+		_ = s.ReplyError(ctx, requestID, ErrorCodeInternal, "failed to resume", "")
+		return fmt.Errorf("resume session %s: %w", s.ID, err)
+	}
+	if resync {
+		return s.Reply(ctx, requestID, MessageTypeResyncRequired, ResyncPayload{LastEventID: lastEventID})
+	}
+
+	for _, entry := range entries {
+		if err := s.sendBacklog(ctx, entry.ID, entry.Data); err != nil {
+			return fmt.Errorf("replay events to session %s: %w", s.ID, err)
+		}
+	}
+	return s.Reply(ctx, requestID, MessageTypeResumed, ResumedPayload{Replayed: len(entries)})
+}
+
+// holdEvents откладывает доставку живых событий до releaseHeld. Шлюз
+// вызывает её до регистрации сессии, чтобы живые события не обогнали
+// пропущенные и офлайн-сообщения.
+func (s *Session) holdEvents() {
+	s.eventsMu.Lock()
+	s.replaying = true
+	s.eventsMu.Unlock()
+}
+
+// releaseHeld отправляет отложенные события по порядку и возобновляет
+// доставку живых. События, пришедшие, пока отложенные ждут места в
+// очереди, тоже откладываются и отправляются следом. Если отложенные
+// события были отброшены из-за переполнения, клиент получает
+// resync_required в ответ на запрос requestID с lastEventID, от которого
+// он возобновлял поток. Если отправка прервалась, оставшиеся события
+// отбрасываются и возвращается ошибка: клиент получит их, возобновив поток.
+func (s *Session) releaseHeld(ctx context.Context, requestID, lastEventID string) error {
+	for {
+		s.eventsMu.Lock()
+		if s.heldOverflow {
+			s.held = nil
+			s.heldOverflow = false
+			s.replaying = false
+			s.eventsMu.Unlock()
+			return s.Reply(ctx, requestID, MessageTypeResyncRequired, ResyncPayload{LastEventID: lastEventID})
+		}
+		if len(s.held) == 0 {
+			s.replaying = false
+			s.eventsMu.Unlock()
+			return nil
+		}
+		entry := s.held[0]
+		s.held = s.held[1:]
+		s.eventsMu.Unlock()
+
+		if err := s.sendBacklog(ctx, entry.ID, entry.Data); err != nil {
+			s.eventsMu.Lock()
+			dropped := len(s.held) + 1
+			s.held = nil
+			s.heldOverflow = false
+			s.replaying = false
+			s.eventsMu.Unlock()
+			return fmt.Errorf("release %d held events of session %s: %w", dropped, s.ID, err)
+		}
+	}
+}
+
+// handleResume обрабатывает конверт resume.
+func (s *Session) handleResume(ctx context.Context, env Envelope) error {
+	var payload ResumePayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		return s.ReplyError(ctx, env.RequestID, ErrorCodeInvalidEnvelope, "failed to decode resume payload", "")
+	}
+	return s.Resume(ctx, env.RequestID, payload.LastEventID)
+}
@@ -0,0 +1,181 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newTestSession возвращает сессию без писателя: кадры остаются в очереди
+// отправки, и тест читает их через queued.
+func newTestSession(t *testing.T, queueSize int, policy string) *Session {
+	t.Helper()
+
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	writerDone := make(chan struct{})
+	close(writerDone)
+	return &Session{
+		ID:         uuid.New(),
+		UserID:     uuid.New(),
+		conn:       server,
+		send:       make(chan outboundFrame, queueSize),
+		policy:     policy,
+		done:       make(chan struct{}),
+		writerDone: writerDone,
+		peerClosed: make(chan struct{}),
+		sent:       make(map[string]struct{}),
+	}
+}
+
+// queued забирает из очереди отправки все кадры.
+func queued(s *Session) []string {
+	var frames []string
+	for {
+		select {
+		case frame := <-s.send:
+			frames = append(frames, string(frame.payload))
+		default:
+			return frames
+		}
+	}
+}
+
+// eventStep — шаг сценария: живое событие или событие из журнала/ящика.
+type eventStep struct {
+	id      string
+	backlog bool
+}
+
+func live(id string) eventStep    { return eventStep{id: id} }
+func backlog(id string) eventStep { return eventStep{id: id, backlog: true} }
+
+func TestSessionHeldEvents(t *testing.T) {
+	tests := []struct {
+		name      string
+		queueSize int
+		hold      bool
+		steps     []eventStep
+		want      []string
+		wantAfter []string
+	}{
+		{
+			name:      "live events are sent immediately without hold",
+			queueSize: 8,
+			steps:     []eventStep{live("1-0"), live("2-0")},
+			want:      []string{"1-0", "2-0"},
+		},
+		{
+			name:      "held live events follow the backlog",
+			queueSize: 8,
+			hold:      true,
+			steps:     []eventStep{live("3-0"), backlog("1-0"), live("4-0"), backlog("2-0")},
+			want:      []string{"1-0", "2-0"},
+			wantAfter: []string{"3-0", "4-0"},
+		},
+		{
+			name:      "events already replayed are not repeated",
+			queueSize: 8,
+			hold:      true,
+			steps:     []eventStep{live("2-0"), backlog("1-0"), backlog("2-0"), live("3-0")},
+			want:      []string{"1-0", "2-0"},
+			wantAfter: []string{"3-0"},
+		},
+		{
+			name:      "held events up to the queue size are kept",
+			queueSize: 2,
+			hold:      true,
+			steps:     []eventStep{live("1-0"), live("2-0")},
+			wantAfter: []string{"1-0", "2-0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s := newTestSession(t, tt.queueSize, OverflowDropOldest)
+			if tt.hold {
+				s.holdEvents()
+			}
+
+			for _, step := range tt.steps {
+				var err error
+				if step.backlog {
+					err = s.deliverBacklog(ctx, step.id, []byte(step.id))
+				} else {
+					err = s.deliver(ctx, step.id, []byte(step.id))
+				}
+				if err != nil {
+					t.Fatalf("deliver %s: %v", step.id, err)
+				}
+			}
+			if got := queued(s); !slices.Equal(got, tt.want) {
+				t.Fatalf("before release = %v, want %v", got, tt.want)
+			}
+
+			if err := s.releaseHeld(ctx, "", ""); err != nil {
+				t.Fatalf("releaseHeld: %v", err)
+			}
+			if got := queued(s); !slices.Equal(got, tt.wantAfter) {
+				t.Fatalf("after release = %v, want %v", got, tt.wantAfter)
+			}
+
+			if err := s.deliver(ctx, "9-0", []byte("9-0")); err != nil {
+				t.Fatalf("deliver after release: %v", err)
+			}
+			if got := queued(s); !slices.Equal(got, []string{"9-0"}) {
+				t.Fatalf("live after release = %v, want [9-0]", got)
+			}
+		})
+	}
+}
+
+func TestSessionHeldOverflowRequiresResync(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSession(t, 2, OverflowDropOldest)
+	s.holdEvents()
+
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0"} {
+		if err := s.deliver(ctx, id, []byte(id)); err != nil {
+			t.Fatalf("deliver %s: %v", id, err)
+		}
+	}
+	if len(s.held) != 0 {
+		t.Fatalf("held = %d events after overflow, want none", len(s.held))
+	}
+
+	if err := s.releaseHeld(ctx, "req-1", "0-1"); err != nil {
+		t.Fatalf("releaseHeld: %v", err)
+	}
+	frames := queued(s)
+	if len(frames) != 1 {
+		t.Fatalf("frames = %v, want a single resync_required", frames)
+	}
+
+	var env struct {
+		Type      string        `json:"type"`
+		RequestID string        `json:"id"`
+		Payload   ResyncPayload `json:"payload"`
+	}
+	if err := json.Unmarshal([]byte(frames[0]), &env); err != nil {
+		t.Fatalf("decode frame: %v", err)
+	}
+	if env.Type != MessageTypeResyncRequired || env.RequestID != "req-1" || env.Payload.LastEventID != "0-1" {
+		t.Fatalf("frame = %+v, want resync_required for req-1 from 0-1", env)
+	}
+
+	if err := s.deliver(ctx, "5-0", []byte("5-0")); err != nil {
+		t.Fatalf("deliver after resync: %v", err)
+	}
+	if got := queued(s); !slices.Equal(got, []string{"5-0"}) {
+		t.Fatalf("live after resync = %v, want [5-0]", got)
+	}
+}
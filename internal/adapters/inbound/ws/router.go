@@ -21,10 +21,11 @@ type HandlerFunc func(ctx context.Context, s *Session, env Envelope) error
 
 // Envelope описывает базовый контракт входящего сообщения WebSocket.
 // Поле Payload содержит JSON-представление конкретного события, которое
-// десериализуется обработчиком.
+// десериализуется обработчиком. Необязательный RequestID задаётся клиентом
+// и возвращается во всех ответах на запрос: ack, nack и error.
 type Envelope struct {
-	Type string `json:"type"`
-	// RequestID uuid.UUID       `json:"id"`
+	Type      string `json:"type"`
+	RequestID string `json:"id,omitempty"`
 	// Timestamp int64           `json:"timestamp"`
 	Payload json.RawMessage `json:"payload"`
 }
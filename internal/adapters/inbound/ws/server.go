@@ -6,9 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"os"
 	"sync"
+	"time"
 
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws/wsutil"
 	"github.com/google/uuid"
@@ -19,33 +24,102 @@ var (
 	sessionPool = make(map[string]*Session)
 )
 
-// Session хранит метаданные WebSocket-подключения.
+// Session хранит метаданные WebSocket-подключения. Исходящие кадры проходят
+// через ограниченную очередь, которую разбирает отдельная горутина-писатель.
 type Session struct {
 	ID     uuid.UUID
 	UserID uuid.UUID
 
 	conn   net.Conn
 	router Router
+
+	send         chan outboundFrame
+	policy       string
+	writeTimeout time.Duration
+	queueMu      sync.Mutex
+	done         chan struct{}
+	writerDone   chan struct{}
+	closeFrame   *outboundFrame
+	closeOnce    sync.Once
+	closeTimeout time.Duration
+	peerClosed   chan struct{}
+	peerOnce     sync.Once
+
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	idleTimeout  time.Duration
+
+	maxMessageSize int64
+
+	eventLog     *EventLog
+	eventsMu     sync.Mutex
+	replaying    bool
+	held         []events.StreamEntry
+	heldOverflow bool
+	sent         map[string]struct{}
 }
 
-// NewSession создаёт сессию и генерирует служебные идентификаторы для трассировки.
-func NewSession(conn net.Conn, router Router) (*Session, error) {
+// NewSession создаёт сессию аутентифицированного пользователя, генерирует
+// идентификатор сессии для трассировки и запускает писателя очереди отправки.
+func NewSession(conn net.Conn, router Router, userID uuid.UUID, cfg *config.WebSocketConfig) (*Session, error) {
 	id, err := uuid.NewV7()
 	if err != nil {
 		return nil, fmt.Errorf("generate session id: %w", err)
 	}
 
-	userID, err := uuid.NewRandom()
-	if err != nil {
-		return nil, fmt.Errorf("generate user id: %w", err)
+	queueSize, policy, writeTimeout := defaultSendQueueSize, OverflowDropOldest, defaultWriteTimeout
+	pingInterval, pongTimeout, idleTimeout := time.Duration(0), defaultPongTimeout, defaultIdleTimeout
+	maxMessageSize, closeTimeout := int64(defaultMaxMessageSize), defaultCloseTimeout
+	if cfg != nil {
+		if cfg.SendQueueSize > 0 {
+			queueSize = cfg.SendQueueSize
+		}
+		if cfg.OverflowPolicy != "" {
+			policy = cfg.OverflowPolicy
+		}
+		if !config.ValidOverflowPolicy(policy) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownOverflowPolicy, policy)
+		}
+		if cfg.WriteTimeout > 0 {
+			writeTimeout = cfg.WriteTimeout
+		}
+		pingInterval = cfg.PingInterval
+		if cfg.PongTimeout > 0 {
+			pongTimeout = cfg.PongTimeout
+		}
+		if cfg.IdleTimeout > 0 {
+			idleTimeout = cfg.IdleTimeout
+		}
+		if cfg.MaxMessageSize > 0 {
+			maxMessageSize = cfg.MaxMessageSize
+		}
+		if cfg.CloseTimeout > 0 {
+			closeTimeout = cfg.CloseTimeout
+		}
 	}
 
-	return &Session{
-		ID:     id,
-		UserID: userID,
-		conn:   conn,
-		router: router,
-	}, nil
+	session := &Session{
+		ID:           id,
+		UserID:       userID,
+		conn:         conn,
+		router:       router,
+		send:         make(chan outboundFrame, queueSize),
+		policy:       policy,
+		writeTimeout: writeTimeout,
+		done:         make(chan struct{}),
+		writerDone:   make(chan struct{}),
+		pingInterval: pingInterval,
+		pongTimeout:  pongTimeout,
+		idleTimeout:  idleTimeout,
+		closeTimeout: closeTimeout,
+		peerClosed:   make(chan struct{}),
+		sent:         make(map[string]struct{}),
+
+		maxMessageSize: maxMessageSize,
+	}
+	go session.writeLoop()
+
+	return session, nil
 }
 
 func registerSession(session *Session) {
@@ -70,33 +144,77 @@ func unregisterSession(sessionID string) {
 	delete(sessionPool, sessionID)
 }
 
-// SendToSession отправляет JSON-сообщение конкретной сессии.
-func SendToSession(ctx context.Context, sessionID string, messageType string, payload any) error {
+// localSessions возвращает снимок сессий, подключённых к текущему узлу.
+func localSessions() []*Session {
+	sessionsMu.RLock()
+	defer sessionsMu.RUnlock()
+
+	sessions := make([]*Session, 0, len(sessionPool))
+	for _, session := range sessionPool {
+		sessions = append(sessions, session)
+	}
+	return sessions
+}
+
+// deliverToSession отправляет готовый конверт события конкретной сессии.
+func deliverToSession(ctx context.Context, sessionID, eventID string, data []byte) error {
 	sessionsMu.RLock()
 	session := sessionPool[sessionID]
 	sessionsMu.RUnlock()
 
 	if session == nil {
-		return fmt.Errorf("session %s not found", sessionID)
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
 	}
 
-	return session.JSON(ctx, messageType, payload)
+	return session.deliver(ctx, eventID, data)
 }
 
-// Close закрывает сетевое соединение.
-func (s *Session) Close() error {
-	if err := s.conn.Close(); err != nil {
-		return fmt.Errorf("close websocket connection: %w", err)
+// SendToSession отправляет JSON-сообщение конкретной сессии.
+func SendToSession(ctx context.Context, sessionID string, messageType string, payload any) error {
+	sessionsMu.RLock()
+	session := sessionPool[sessionID]
+	sessionsMu.RUnlock()
+
+	if session == nil {
+		return fmt.Errorf("%w: %s", ErrSessionNotFound, sessionID)
 	}
-	return nil
+
+	return session.JSON(ctx, messageType, payload)
 }
 
 // ReadLoop непрерывно читает сообщения клиента и передаёт их роутеру.
+// Управляющие кадры обрабатываются здесь же, а ответы на них проходят через
+// очередь отправки, как и остальные кадры. Каждый кадр клиента продлевает
+// дедлайн чтения на IdleTimeout; по истечении дедлайна соединение считается
+// мёртвым и закрывается.
 func (s *Session) ReadLoop(ctx context.Context) error {
+	control := func(hdr ws.Header, r io.Reader) error {
+		return s.handleControl(ctx, hdr, r)
+	}
+	reader := &wsutil.Reader{
+		Source:         s.conn,
+		State:          ws.StateServerSide,
+		CheckUTF8:      true,
+		OnIntermediate: control,
+	}
+
 	for {
-		msg, op, err := wsutil.ReadClientData(s.conn)
+		msg, op, err := s.readClientData(reader, control)
 		if err != nil {
-			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				deadConnectionCloses.Add(1)
+				slog.Debug("websocket connection timed out",
+					slog.String("session_id", s.ID.String()),
+				)
+				_ = s.closeWithStatus(CloseGoingAway, "idle timeout")
+				return nil
+			}
+			if errors.Is(err, ErrMessageTooBig) {
+				_ = s.closeWithStatus(CloseMessageTooBig, "message too big")
+				return nil
+			}
+			var closed wsutil.ClosedError
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) || errors.As(err, &closed) {
 				return nil
 			}
 			return fmt.Errorf("read client data: %w", err)
@@ -106,49 +224,117 @@ func (s *Session) ReadLoop(ctx context.Context) error {
 			if errors.Is(err, ErrNoRouteMatched) {
 				continue
 			}
+			if errors.Is(err, ErrSessionClosed) {
+				return nil
+			}
 			return err
 		}
 	}
 }
 
-// WriteMessage отправляет сообщение клиенту с указанным типом опкода.
-func (s *Session) WriteMessage(ctx context.Context, op ws.OpCode, payload []byte) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
+// readClientData читает следующее сообщение с данными, передавая
+// управляющие кадры в control. Сообщения длиннее maxMessageSize
+// отклоняются с ErrMessageTooBig.
+func (s *Session) readClientData(reader *wsutil.Reader, control wsutil.FrameHandlerFunc) ([]byte, ws.OpCode, error) {
+	for {
+		if err := s.conn.SetReadDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			return nil, 0, fmt.Errorf("set read deadline: %w", err)
+		}
+		hdr, err := reader.NextFrame()
+		if err != nil {
+			return nil, 0, fmt.Errorf("next frame: %w", err)
+		}
+		if hdr.OpCode.IsControl() {
+			if err := control(hdr, reader); err != nil {
+				return nil, 0, err
+			}
+			continue
+		}
+
+		if hdr.Length > s.maxMessageSize {
+			return nil, 0, ErrMessageTooBig
+		}
+		payload, err := io.ReadAll(io.LimitReader(reader, s.maxMessageSize+1))
+		if err != nil {
+			return nil, 0, fmt.Errorf("read frame payload: %w", err)
+		}
+		if int64(len(payload)) > s.maxMessageSize {
+			return nil, 0, ErrMessageTooBig
+		}
+		return payload, hdr.OpCode, nil
+	}
+}
+
+// handleControl обрабатывает ping, pong и close кадры клиента.
+func (s *Session) handleControl(ctx context.Context, hdr ws.Header, r io.Reader) error {
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("read control frame: %w", err)
 	}
 
-	if err := wsutil.WriteServerMessage(s.conn, op, payload); err != nil {
-		return fmt.Errorf("write server message: %w", err)
+	switch hdr.OpCode {
+	case ws.OpPing:
+		// Pong не подчиняется политике переполнения: он не должен вытеснять
+		// события или отключать клиента. При полной очереди pong
+		// отбрасывается, клиент повторит ping.
+		err := s.tryEnqueue(outboundFrame{op: ws.OpPong, payload: payload})
+		if errors.Is(err, ErrSendQueueFull) {
+			sendQueueDropped.Add(1)
+			return nil
+		}
+		return err
+	case ws.OpClose:
+		s.peerOnce.Do(func() { close(s.peerClosed) })
+
+		if len(payload) == 0 {
+			_ = s.closeWith(&outboundFrame{op: ws.OpClose})
+			return wsutil.ClosedError{Code: ws.StatusNoStatusRcvd}
+		}
+		code, reason := ws.ParseCloseFrameData(payload)
+		if err := ws.CheckCloseFrameData(code, reason); err != nil {
+			_ = s.closeWithStatus(CloseProtocolError, "invalid close frame")
+			return wsutil.ClosedError{Code: CloseProtocolError, Reason: err.Error()}
+		}
+		_ = s.closeWith(echoCloseFrame(code))
+		return wsutil.ClosedError{Code: code, Reason: reason}
+	default:
+		return nil
 	}
-	return nil
 }
 
 func (s *Session) handleOperation(ctx context.Context, op ws.OpCode, payload []byte) error {
 	switch op {
 	case ws.OpText:
+		// Каждый конверт — отдельный спан, дочерний к трассе сессии.
+		ctx = events.WithTraceParent(ctx, events.NewTraceParent(events.TraceParent(ctx)))
+
 		var env Envelope
 
 		if err := json.Unmarshal(payload, &env); err != nil {
 			return s.Error(ctx, ErrorCodeInvalidEnvelope, "failed to decode envelope", "")
 		}
+		if len(env.RequestID) > maxRequestIDLength {
+			return s.Error(ctx, ErrorCodeInvalidEnvelope, "request id is too long", "")
+		}
+		if env.Type == MessageTypeResume {
+			return s.handleResume(ctx, env)
+		}
 		if s.router == nil {
-			return s.Error(ctx, ErrorCodeInternal, "router is not configured", "")
+			return s.ReplyError(ctx, env.RequestID, ErrorCodeInternal, "router is not configured", "")
 		}
 		if err := s.router.Route(ctx, s, env); err != nil {
 			if errors.Is(err, ErrNoRouteMatched) {
-				return s.Error(ctx, ErrorCodeRouteNotFound, "no handler for envelope type", "")
+				return s.ReplyError(ctx, env.RequestID, ErrorCodeRouteNotFound, "no handler for envelope type", "")
+			}
+			var clientErr ClientError
+			if errors.As(err, &clientErr) {
+				payload := clientErr.ClientPayload()
+				return s.ReplyError(ctx, env.RequestID, payload.Code, payload.Message, payload.Details)
 			}
-			_ = s.Error(ctx, ErrorCodeInternal, "handler execution failed", "")
+			_ = s.ReplyError(ctx, env.RequestID, ErrorCodeInternal, "handler execution failed", "")
 			return fmt.Errorf("route websocket envelope: %w", err)
 		}
 		return nil
-	case ws.OpPing:
-		if err := wsutil.WriteServerMessage(s.conn, ws.OpPong, nil); err != nil {
-			return fmt.Errorf("write pong: %w", err)
-		}
-		return nil
 	default:
 		return nil
 	}
@@ -156,10 +342,17 @@ func (s *Session) handleOperation(ctx context.Context, op ws.OpCode, payload []b
 
 // JSON сериализует payload и отправляет его клиенту как текстовое сообщение.
 func (s *Session) JSON(ctx context.Context, messageType string, payload any) error {
+	return s.Reply(ctx, "", messageType, payload)
+}
+
+// Reply отправляет клиенту конверт, связанный с запросом requestID. Пустой
+// requestID опускается.
+func (s *Session) Reply(ctx context.Context, requestID, messageType string, payload any) error {
 	body := struct {
-		Type    string `json:"type"`
-		Payload any    `json:"payload"`
-	}{messageType, payload}
+		Type      string `json:"type"`
+		RequestID string `json:"id,omitempty"`
+		Payload   any    `json:"payload"`
+	}{messageType, requestID, payload}
 
 	data, err := json.Marshal(body)
 	if err != nil {
@@ -169,10 +362,17 @@ func (s *Session) JSON(ctx context.Context, messageType string, payload any) err
 	return s.WriteMessage(ctx, ws.OpText, data)
 }
 
+// Error отправляет клиенту конверт ошибки, не связанный с запросом.
 func (s *Session) Error(ctx context.Context, code, message, details string) error {
+	return s.ReplyError(ctx, "", code, message, details)
+}
+
+// ReplyError отправляет клиенту конверт ошибки с идентификатором запроса,
+// который её вызвал.
+func (s *Session) ReplyError(ctx context.Context, requestID, code, message, details string) error {
 	payload := ErrorPayload{Code: code, Message: message}
 	if details != "" {
 		payload.Details = details
 	}
-	return s.JSON(ctx, MessageTypeError, payload)
+	return s.Reply(ctx, requestID, MessageTypeError, payload)
 }
@@ -0,0 +1,53 @@
+package ws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/gobwas/ws"
+)
+
+// traceRouter запоминает traceparent, с которым обработан каждый конверт.
+type traceRouter struct {
+	traces []string
+}
+
+func (r *traceRouter) Route(ctx context.Context, _ *Session, _ Envelope) error {
+	r.traces = append(r.traces, events.TraceParent(ctx))
+	return nil
+}
+
+func TestHandleOperationStartsSpanPerEnvelope(t *testing.T) {
+	const session = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	router := &traceRouter{}
+	s := newTestSession(t, 8, OverflowDropOldest)
+	s.router = router
+	ctx := events.WithTraceParent(context.Background(), session)
+
+	for range 2 {
+		if err := s.handleOperation(ctx, ws.OpText, []byte(`{"type":"ping","payload":{}}`)); err != nil {
+			t.Fatalf("handleOperation: %v", err)
+		}
+	}
+
+	if len(router.traces) != 2 {
+		t.Fatalf("routed %d envelopes, want 2", len(router.traces))
+	}
+	spans := map[string]bool{"00f067aa0ba902b7": true}
+	for _, trace := range router.traces {
+		parts := strings.Split(trace, "-")
+		if len(parts) != 4 {
+			t.Fatalf("traceparent = %q, want a valid traceparent", trace)
+		}
+		if parts[1] != "4bf92f3577b34da6a3ce929d0e0e4736" || parts[3] != "01" {
+			t.Fatalf("traceparent = %s, want a span of the session trace", trace)
+		}
+		if spans[parts[2]] {
+			t.Fatalf("span id %s is reused", parts[2])
+		}
+		spans[parts[2]] = true
+	}
+}
@@ -0,0 +1,215 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+const (
+	eventStreamPrefix = "events:"
+	// eventIDPrefix — префикс ключа "event-id:<user_id>:<event_key>" с
+	// идентификатором события, уже записанного в поток.
+	eventIDPrefix = "event-id:"
+)
+
+// eventStore — хранилище потоков событий пользователей. Идентификаторы
+// записей назначает хранилище в формате "<ms>-<seq>".
+type eventStore interface {
+	AddStream(ctx context.Context, key string, data []byte, maxLen int64, expiration time.Duration) (string, error)
+	AddStreamOnce(ctx context.Context, key, idKey string, data []byte, maxLen int64, expiration time.Duration) (string, error)
+	RangeStream(ctx context.Context, key, start, stop string, count int64) ([]events.StreamEntry, error)
+	StreamMaxDeletedID(ctx context.Context, key string) (string, error)
+}
+
+// EventLog хранит доставляемые пользователю события в ограниченном потоке,
+// чтобы клиент после короткого обрыва связи мог получить пропущенное.
+// Поток хранит примерно MaxLen последних событий и удаляется, если в него
+// ничего не писали дольше TTL.
+type EventLog struct {
+	store  eventStore
+	maxLen int64
+	ttl    time.Duration
+}
+
+// EventLogDeps агрегирует зависимости журнала событий.
+type EventLogDeps struct {
+	Store  eventStore
+	MaxLen int64
+	TTL    time.Duration
+}
+
+// NewEventLog создаёт журнал событий пользователей.
+func NewEventLog(deps *EventLogDeps) *EventLog {
+	if deps == nil || deps.Store == nil {
+		panic("event store cannot be nil")
+	}
+
+	return &EventLog{
+		store:  deps.Store,
+		maxLen: deps.MaxLen,
+		ttl:    deps.TTL,
+	}
+}
+
+// streamRecord — событие в том виде, в каком оно хранится в потоке.
+type streamRecord struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// eventEnvelope — конверт события, отправляемый клиенту.
+type eventEnvelope struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id,omitempty"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Append записывает событие в поток пользователя и возвращает его
+// идентификатор вместе с готовым конвертом для отправки клиенту. Событие с
+// непустым eventKey записывается один раз: повторная доставка того же
+// события получает прежний идентификатор, и клиент с возобновлением потока
+// не увидят дубликатов.
+func (l *EventLog) Append(ctx context.Context, userID, eventKey, messageType string, payload any) (string, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal %s payload: %w", messageType, err)
+	}
+	record, err := json.Marshal(streamRecord{Type: messageType, Payload: body})
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal %s record: %w", messageType, err)
+	}
+
+	var id string
+	if eventKey != "" {
+		id, err = l.store.AddStreamOnce(ctx, eventStreamPrefix+userID, eventIDPrefix+userID+":"+eventKey, record, l.maxLen, l.ttl)
+	} else {
+		id, err = l.store.AddStream(ctx, eventStreamPrefix+userID, record, l.maxLen, l.ttl)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("append event for %s: %w", userID, err)
+	}
+
+	data, err := json.Marshal(eventEnvelope{Type: messageType, EventID: id, Payload: body})
+	if err != nil {
+		return "", nil, fmt.Errorf("marshal %s envelope: %w", messageType, err)
+	}
+	return id, data, nil
+}
+
+// Since возвращает конверты событий пользователя, записанных после
+// lastEventID, в порядке записи. resync равен true, если часть событий
+// после lastEventID уже вытеснена из потока и восстановить разрыв нельзя.
+// Если вытеснены только события, которые клиент уже получил, resync не
+// нужен: это проверяется по наибольшему удалённому идентификатору потока.
+func (l *EventLog) Since(ctx context.Context, userID, lastEventID string) ([]events.StreamEntry, bool, error) {
+	last, ok := parseEventID(lastEventID)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: %q", ErrInvalidEventID, lastEventID)
+	}
+
+	key := eventStreamPrefix + userID
+	oldest, err := l.store.RangeStream(ctx, key, "-", "+", 1)
+	if err != nil {
+		return nil, false, fmt.Errorf("read oldest event of %s: %w", userID, err)
+	}
+	if len(oldest) == 0 {
+		// Поток пуст: либо событий не было, либо он истёк по TTL вместе с
+		// событиями, которых клиент мог не видеть.
+		expired := l.ttl > 0 && time.Since(time.UnixMilli(int64(last.ms))) > l.ttl
+		return nil, expired, nil
+	}
+	if first, _ := parseEventID(oldest[0].ID); last.less(first) {
+		lost, err := l.trimmedUnseen(ctx, key, last)
+		if err != nil {
+			return nil, false, fmt.Errorf("read trimmed events of %s: %w", userID, err)
+		}
+		if lost {
+			return nil, true, nil
+		}
+	}
+
+	stored, err := l.store.RangeStream(ctx, key, "("+lastEventID, "+", 0)
+	if err != nil {
+		return nil, false, fmt.Errorf("read events of %s since %s: %w", userID, lastEventID, err)
+	}
+
+	entries := make([]events.StreamEntry, 0, len(stored))
+	for _, entry := range stored {
+		var record streamRecord
+		if err := json.Unmarshal(entry.Data, &record); err != nil {
+			return nil, false, fmt.Errorf("decode event %s: %w", entry.ID, err)
+		}
+		data, err := json.Marshal(eventEnvelope{Type: record.Type, EventID: entry.ID, Payload: record.Payload})
+		if err != nil {
+			return nil, false, fmt.Errorf("marshal event %s: %w", entry.ID, err)
+		}
+		entries = append(entries, events.StreamEntry{ID: entry.ID, Data: data})
+	}
+	return entries, false, nil
+}
+
+// trimmedUnseen сообщает, были ли среди удалённых из потока записей более
+// поздние, чем last. Если хранилище не знает удалённых записей или удалений
+// не было, а первая запись всё равно позже last, поток пересоздан после
+// истечения TTL, и разрыв считается невосстановимым.
+func (l *EventLog) trimmedUnseen(ctx context.Context, key string, last eventID) (bool, error) {
+	value, err := l.store.StreamMaxDeletedID(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	deleted, ok := parseEventID(value)
+	if !ok || deleted == (eventID{}) {
+		return true, nil
+	}
+	return last.less(deleted), nil
+}
+
+// eventID — разобранный идентификатор записи потока "<ms>-<seq>".
+type eventID struct {
+	ms  uint64
+	seq uint64
+}
+
+func parseEventID(value string) (eventID, bool) {
+	msPart, seqPart, ok := strings.Cut(value, "-")
+	if !ok {
+		return eventID{}, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return eventID{}, false
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return eventID{}, false
+	}
+	return eventID{ms: ms, seq: seq}, true
+}
+
+func (id eventID) less(other eventID) bool {
+	if id.ms != other.ms {
+		return id.ms < other.ms
+	}
+	return id.seq < other.seq
+}
+
+// eventIDLess сравнивает идентификаторы событий. Некорректный идентификатор
+// считается меньше любого корректного.
+func eventIDLess(a, b string) bool {
+	left, okA := parseEventID(a)
+	right, okB := parseEventID(b)
+	switch {
+	case !okB:
+		return false
+	case !okA:
+		return true
+	default:
+		return left.less(right)
+	}
+}
@@ -0,0 +1,149 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+// memoryStream — поток событий в памяти. maxDeleted имитирует XINFO
+// STREAM: пустая строка означает, что сервер не сообщает это значение.
+type memoryStream struct {
+	entries    []events.StreamEntry
+	maxDeleted string
+}
+
+func (m *memoryStream) AddStream(_ context.Context, _ string, data []byte, _ int64, _ time.Duration) (string, error) {
+	id := fmt.Sprintf("%d-0", len(m.entries)+1)
+	m.entries = append(m.entries, events.StreamEntry{ID: id, Data: data})
+	return id, nil
+}
+
+func (m *memoryStream) AddStreamOnce(ctx context.Context, key, _ string, data []byte, maxLen int64, ttl time.Duration) (string, error) {
+	return m.AddStream(ctx, key, data, maxLen, ttl)
+}
+
+func (m *memoryStream) RangeStream(_ context.Context, _, start, _ string, count int64) ([]events.StreamEntry, error) {
+	var out []events.StreamEntry
+	for _, entry := range m.entries {
+		if after, ok := strings.CutPrefix(start, "("); ok && !eventIDLess(after, entry.ID) {
+			continue
+		}
+		out = append(out, entry)
+		if count > 0 && int64(len(out)) == count {
+			break
+		}
+	}
+	return out, nil
+}
+
+func (m *memoryStream) StreamMaxDeletedID(context.Context, string) (string, error) {
+	return m.maxDeleted, nil
+}
+
+// streamOf возвращает поток с записями ids.
+func streamOf(maxDeleted string, ids ...string) *memoryStream {
+	stream := &memoryStream{maxDeleted: maxDeleted}
+	for _, id := range ids {
+		stream.entries = append(stream.entries, events.StreamEntry{
+			ID:   id,
+			Data: []byte(`{"type":"message_delivered","payload":{}}`),
+		})
+	}
+	return stream
+}
+
+func TestEventLogSince(t *testing.T) {
+	recent := fmt.Sprintf("%d-0", time.Now().Add(-time.Minute).UnixMilli())
+	stale := fmt.Sprintf("%d-0", time.Now().Add(-48*time.Hour).UnixMilli())
+
+	tests := []struct {
+		name       string
+		stream     *memoryStream
+		last       string
+		want       []string
+		wantResync bool
+	}{
+		{
+			name:   "returns events after the last one",
+			stream: streamOf("0-0", "10-0", "11-0", "12-0"),
+			last:   "10-0",
+			want:   []string{"11-0", "12-0"},
+		},
+		{
+			name:   "client is up to date",
+			stream: streamOf("0-0", "10-0", "11-0"),
+			last:   "11-0",
+		},
+		{
+			name:   "empty stream within ttl",
+			stream: streamOf(""),
+			last:   recent,
+		},
+		{
+			name:       "empty stream past ttl",
+			stream:     streamOf(""),
+			last:       stale,
+			wantResync: true,
+		},
+		{
+			name:       "trimmed events were unseen",
+			stream:     streamOf("11-0", "12-0", "13-0"),
+			last:       "10-0",
+			wantResync: true,
+		},
+		{
+			name:   "only seen events were trimmed",
+			stream: streamOf("10-0", "12-0", "13-0"),
+			last:   "11-0",
+			want:   []string{"12-0", "13-0"},
+		},
+		{
+			name:       "stream recreated after expiry",
+			stream:     streamOf("0-0", "12-0"),
+			last:       "10-0",
+			wantResync: true,
+		},
+		{
+			name:       "trimmed ids unknown",
+			stream:     streamOf("", "12-0"),
+			last:       "10-0",
+			wantResync: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := NewEventLog(&EventLogDeps{Store: tt.stream, TTL: 24 * time.Hour})
+
+			entries, resync, err := log.Since(context.Background(), "user", tt.last)
+			if err != nil {
+				t.Fatalf("Since: %v", err)
+			}
+			if resync != tt.wantResync {
+				t.Fatalf("resync = %v, want %v", resync, tt.wantResync)
+			}
+
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventLogSinceRejectsInvalidID(t *testing.T) {
+	log := NewEventLog(&EventLogDeps{Store: streamOf("")})
+
+	if _, _, err := log.Since(context.Background(), "user", "not-an-id"); err == nil {
+		t.Fatal("Since accepted an invalid event id")
+	}
+}
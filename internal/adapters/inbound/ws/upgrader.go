@@ -2,53 +2,127 @@ package ws
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
-	"strings"
+	"sync"
 	"time"
 
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
 	"github.com/gobwas/ws"
+	"github.com/google/uuid"
 )
 
 // SessionStore описывает операции с хранилищем активных WebSocket-сессий.
+// Сессии пользователя хранятся множеством: AddMember и RemoveMember должны
+// выполняться атомарно, чтобы параллельные подключения одного пользователя
+// не затирали друг друга. Каждый элемент множества имеет собственный TTL.
 type SessionStore interface {
 	Add(ctx context.Context, key string, value any, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	Remove(ctx context.Context, keys ...string) error
+	AddMember(ctx context.Context, key, member string, expiration time.Duration) error
+	RemoveMember(ctx context.Context, key, member string) error
+	Members(ctx context.Context, key string) ([]string, error)
 }
 
 // Gateway обслуживает HTTP-upgrade в WebSocket и управляет регистрацией сессий.
 type Gateway struct {
-	store  SessionStore
-	router Router
+	registry *Registry
+	router   Router
+	verifier TokenVerifier
+	inbox    *Inbox
+	events   *EventLog
+	cfg      *config.WebSocketConfig
+
+	mu       sync.Mutex
+	draining bool
+	handlers sync.WaitGroup
+}
+
+// GatewayDeps агрегирует зависимости шлюза. Inbox необязателен: если он
+// задан, новая сессия сразу получает сообщения, накопленные, пока
+// пользователь был офлайн. Events тоже необязателен: без него сессии не
+// поддерживают возобновление потока по last_event_id.
+type GatewayDeps struct {
+	Registry *Registry
+	Router   Router
+	Verifier TokenVerifier
+	Inbox    *Inbox
+	Events   *EventLog
+	Cfg      *config.WebSocketConfig
 }
 
-// NewGateway создаёт экземпляр шлюза с переданным хранилищем и маршрутизатором.
-func NewGateway(store SessionStore, router Router) *Gateway {
-	if store == nil {
-		panic("session store cannot be nil")
+// NewGateway создаёт экземпляр шлюза с переданным реестром сессий,
+// маршрутизатором и верификатором токенов.
+func NewGateway(deps *GatewayDeps) *Gateway {
+	if deps == nil || deps.Registry == nil {
+		panic("session registry cannot be nil")
 	}
-	if router == nil {
+	if deps.Router == nil {
 		panic("router cannot be nil")
 	}
+	if deps.Verifier == nil {
+		panic("token verifier cannot be nil")
+	}
+	if deps.Cfg == nil {
+		panic("websocket config cannot be nil")
+	}
 
 	return &Gateway{
-		store:  store,
-		router: router,
+		registry: deps.Registry,
+		router:   deps.Router,
+		verifier: deps.Verifier,
+		inbox:    deps.Inbox,
+		events:   deps.Events,
+		cfg:      deps.Cfg,
 	}
 }
 
-// HandleWS обрабатывает upgrade, регистрирует сессию и запускает ReadLoop.
+// HandleWS аутентифицирует запрос, выполняет upgrade, регистрирует сессию,
+// отдаёт ей накопленные офлайн-сообщения и запускает ReadLoop. Если в
+// запросе передан last_event_id, до живых событий сессия получает
+// пропущенные. Заголовок traceparent запроса задаёт трассу сессии: каждый
+// конверт клиента обрабатывается в собственном дочернем спане, и события,
+// опубликованные по нему, продолжают эту трассу.
+// Запросы без валидного токена отклоняются ответом 401 до upgrade, а во
+// время остановки узла или недоступности верификатора — ответом 503 с
+// Retry-After.
 func (g *Gateway) HandleWS(w http.ResponseWriter, r *http.Request) {
-	conn, _, _, err := ws.UpgradeHTTP(r, w)
+	if !g.acquire() {
+		_, _, jitter := g.drainSettings()
+		serviceUnavailable(w, jitter)
+		return
+	}
+	defer g.handlers.Done()
+
+	userID, expiresAt, viaProtocol, err := g.authenticate(r)
+	if err != nil {
+		slog.Debug("websocket upgrade rejected",
+			slog.String("remote_addr", r.RemoteAddr),
+			slog.String("error", err.Error()),
+		)
+		if verifierUnavailable(err) {
+			authUnavailable(w)
+			return
+		}
+		unauthorized(w)
+		return
+	}
+
+	upgrader := ws.HTTPUpgrader{
+		Protocol: func(protocol string) bool {
+			return viaProtocol && protocol == bearerProtocol
+		},
+	}
+	conn, _, _, err := upgrader.Upgrade(r, w)
 	if err != nil {
 		http.Error(w, "upgrade failed", http.StatusBadRequest)
 		return
 	}
 
-	session, err := NewSession(conn, g.router)
+	session, err := NewSession(conn, g.router, userID, g.cfg)
 	if err != nil {
 		_ = conn.Close()
 		http.Error(w, "failed to create session", http.StatusInternalServerError)
@@ -59,16 +133,68 @@ func (g *Gateway) HandleWS(w http.ResponseWriter, r *http.Request) {
 		slog.String("user_id", session.UserID.String()),
 		slog.String("session_id", session.ID.String()),
 	)
-	ctx, cancel := context.WithCancel(r.Context())
+	session.eventLog = g.events
+	lastEventID := r.URL.Query().Get(ResumeQueryParam)
+	// Живые события откладываются до выдачи пропущенных и офлайн-событий,
+	// иначе они обгонят их.
+	session.holdEvents()
+
+	// Трасса, переданная в заголовке traceparent запроса upgrade, —
+	// родитель сессии: каждый конверт клиента получает в ней свой спан.
+	ctx, cancel := context.WithCancel(events.WithTraceParent(r.Context(), r.Header.Get(events.HeaderTraceParent)))
 	defer cancel()
 	defer g.sessionRemove(ctx, session)
 
-	if err := g.appendSession(ctx, session.UserID.String(), session.ID.String()); err != nil {
+	// Сессия попадает в локальный пул до записи в Redis: Notify, увидевший
+	// её в реестре, должен найти её здесь. События, пришедшие до выдачи
+	// накопленных, откладываются.
+	registerSession(session)
+	if err := g.registry.Register(ctx, session); err != nil {
+		_ = session.releaseHeld(ctx, "", lastEventID)
 		_ = session.Close()
 		http.Error(w, "failed to register session", http.StatusInternalServerError)
 		return
 	}
-	registerSession(session)
+
+	// Сессия могла зарегистрироваться уже после того, как Drain собрал
+	// список локальных сессий. Офлайн-сообщения такой сессии не выдаются,
+	// чтобы не забрать их из ящика в закрывающееся соединение. Перед
+	// выходом обработчик ждёт отправки закрывающего кадра.
+	if g.isDraining() {
+		_ = session.CloseWithReason(ctx, CloseGoingAway, "server shutting down")
+		<-session.writerDone
+		return
+	}
+
+	// Поток возобновляется до выдачи офлайн-сообщений: события, уже
+	// повторенные из журнала, Flush пропускает.
+	if lastEventID != "" {
+		if err := session.replay(ctx, "", lastEventID); err != nil {
+			slog.Warn("failed to resume event stream",
+				slog.String("session_id", session.ID.String()),
+				slog.String("error", err.Error()),
+			)
+		}
+	}
+	g.flushInbox(ctx, session)
+	if err := session.releaseHeld(ctx, "", lastEventID); err != nil {
+		// Без отложенных событий клиент увидел бы разрыв в потоке, поэтому
+		// сессия закрывается: переподключившись, клиент возобновит поток.
+		slog.Warn("failed to deliver held events",
+			slog.String("session_id", session.ID.String()),
+			slog.String("error", err.Error()),
+		)
+		_ = session.CloseWithReason(ctx, CloseInternalError, "failed to deliver pending events")
+		<-session.writerDone
+		return
+	}
+
+	if !expiresAt.IsZero() {
+		expiry := time.AfterFunc(time.Until(expiresAt), func() {
+			_ = session.CloseWithReason(ctx, CloseAuthExpired, "token expired")
+		})
+		defer expiry.Stop()
+	}
 
 	if err := session.ReadLoop(ctx); err != nil {
 		slog.Error("websocket session read loop failed",
@@ -78,13 +204,34 @@ func (g *Gateway) HandleWS(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// authenticate извлекает и проверяет токен запроса. Идентификатор пользователя
+// берётся из subject токена и должен быть UUID. Вместе с ним возвращается
+// срок действия токена: по его истечении сессия закрывается с кодом 4001.
+func (g *Gateway) authenticate(r *http.Request) (uuid.UUID, time.Time, bool, error) {
+	token, viaProtocol := bearerToken(r)
+	if token == "" {
+		return uuid.Nil, time.Time{}, false, ErrMissingToken
+	}
+
+	identity, err := g.verifier.Verify(r.Context(), token)
+	if err != nil {
+		return uuid.Nil, time.Time{}, false, fmt.Errorf("verify token: %w", err)
+	}
+
+	userID, err := uuid.Parse(identity.Subject)
+	if err != nil {
+		return uuid.Nil, time.Time{}, false, fmt.Errorf("parse token subject: %w", err)
+	}
+	return userID, identity.ExpiresAt, viaProtocol, nil
+}
+
 func (g *Gateway) sessionRemove(ctx context.Context, session *Session) {
 	if session == nil {
 		return
 	}
 	unregisterSession(session.ID.String())
 
-	if err := g.removeSession(ctx, session.UserID.String(), session.ID.String()); err != nil {
+	if err := g.registry.Unregister(ctx, session); err != nil {
 		slog.Warn("failed to remove websocket session",
 			slog.String("session_id", session.ID.String()),
 			slog.String("error", err.Error()),
@@ -98,74 +245,15 @@ func (g *Gateway) sessionRemove(ctx context.Context, session *Session) {
 	}
 }
 
-func (g *Gateway) appendSession(ctx context.Context, userID, sessionID string) error {
-	key := "session:" + userID
-	sessions, err := g.readSessions(ctx, key)
-	if err != nil {
-		return fmt.Errorf("read sessions %s: %w", key, err)
-	}
-
-	for _, existing := range sessions {
-		if existing == sessionID {
-			return nil
-		}
-	}
-	sessions = append(sessions, sessionID)
-	return g.writeSessions(ctx, key, sessions)
-}
-
-func (g *Gateway) removeSession(ctx context.Context, userID, sessionID string) error {
-	key := "session:" + userID
-	sessions, err := g.readSessions(ctx, key)
-	if err != nil {
-		if isNotFound(err) {
-			return nil
-		}
-		return fmt.Errorf("read sessions %s: %w", key, err)
-	}
-
-	filtered := sessions[:0]
-	for _, existing := range sessions {
-		if existing != sessionID {
-			filtered = append(filtered, existing)
-		}
-	}
-	if len(filtered) == 0 {
-		if err := g.store.Remove(ctx, key); err != nil {
-			return fmt.Errorf("remove key %s: %w", key, err)
-		}
-		return nil
-	}
-	return g.writeSessions(ctx, key, filtered)
-}
-
-func (g *Gateway) readSessions(ctx context.Context, key string) ([]string, error) {
-	value, err := g.store.Get(ctx, key)
-	if err != nil {
-		if isNotFound(err) {
-			return []string{}, nil
-		}
-		return nil, fmt.Errorf("get key %s: %w", key, err)
-	}
-
-	var sessions []string
-	if err := json.Unmarshal([]byte(value), &sessions); err != nil {
-		return nil, fmt.Errorf("decode sessions for %s: %w", key, err)
-	}
-	return sessions, nil
-}
-
-func (g *Gateway) writeSessions(ctx context.Context, key string, sessions []string) error {
-	payload, err := json.Marshal(sessions)
-	if err != nil {
-		return fmt.Errorf("encode sessions for %s: %w", key, err)
+// flushInbox выдаёт сессии офлайн-сообщения её пользователя.
+func (g *Gateway) flushInbox(ctx context.Context, session *Session) {
+	if g.inbox == nil {
+		return
 	}
-	if err := g.store.Add(ctx, key, string(payload), 0); err != nil {
-		return fmt.Errorf("store sessions %s: %w", key, err)
+	if err := g.inbox.Flush(ctx, session); err != nil {
+		slog.Warn("failed to flush offline inbox",
+			slog.String("session_id", session.ID.String()),
+			slog.String("error", err.Error()),
+		)
 	}
-	return nil
-}
-
-func isNotFound(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "key not found")
 }
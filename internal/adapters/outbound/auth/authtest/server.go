@@ -0,0 +1,185 @@
+// Package authtest предоставляет in-process реализацию AuthService поверх
+// in-memory listener, чтобы проверять gRPC-верификатор без сети.
+//
+// Пример:
+//
+//	srv := authtest.NewServer()
+//	defer srv.Close()
+//
+//	token, _ := srv.IssueToken(userID, time.Hour)
+//	verifier := auth.NewGRPCVerifier(&auth.GRPCVerifierDeps{
+//		Cfg:         &config.AuthConfig{GRPC: config.AuthGRPCConfig{Address: authtest.Target, Insecure: true}},
+//		Log:         log,
+//		DialOptions: srv.DialOptions(),
+//	})
+package authtest
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/DENFNC/devPractice/gen/go/service/v1"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Target — адрес, который нужно передать клиенту вместе с DialOptions.
+const Target = "passthrough:///authtest"
+
+const bufferSize = 1 << 20
+
+type authSession struct {
+	id        string
+	subject   string
+	issuedAt  time.Time
+	expiresAt time.Time
+	revokedAt time.Time
+}
+
+func (s *authSession) active(now time.Time) bool {
+	return s.revokedAt.IsZero() && now.Before(s.expiresAt)
+}
+
+// Server — потокобезопасная in-memory реализация pb.AuthServiceServer.
+type Server struct {
+	pb.UnimplementedAuthServiceServer
+
+	listener *bufconn.Listener
+	server   *grpc.Server
+
+	mu       sync.RWMutex
+	tokens   map[string]*authSession
+	sessions map[string]*authSession
+}
+
+// NewServer запускает gRPC-сервер на in-memory listener.
+func NewServer() *Server {
+	s := &Server{
+		listener: bufconn.Listen(bufferSize),
+		server:   grpc.NewServer(),
+		tokens:   make(map[string]*authSession),
+		sessions: make(map[string]*authSession),
+	}
+	pb.RegisterAuthServiceServer(s.server, s)
+
+	go func() {
+		_ = s.server.Serve(s.listener)
+	}()
+
+	return s
+}
+
+// DialOptions возвращает опции клиента для подключения к серверу через Target.
+func (s *Server) DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.listener.DialContext(ctx)
+		}),
+	}
+}
+
+// Close останавливает сервер и закрывает listener.
+func (s *Server) Close() {
+	s.server.Stop()
+	_ = s.listener.Close()
+}
+
+// IssueToken создаёт новую сессию для subject и возвращает токен и
+// идентификатор сессии.
+func (s *Server) IssueToken(subject string, ttl time.Duration) (string, string) {
+	now := time.Now()
+	session := &authSession{
+		id:        uuid.NewString(),
+		subject:   subject,
+		issuedAt:  now,
+		expiresAt: now.Add(ttl),
+	}
+	token := uuid.NewString()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[token] = session
+	s.sessions[session.id] = session
+	return token, session.id
+}
+
+// ValidateToken реализует pb.AuthServiceServer.
+func (s *Server) ValidateToken(_ context.Context, req *pb.ValidateTokenRequest) (*pb.ValidateTokenResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is empty")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.tokens[req.GetToken()]
+	if !ok || !session.active(time.Now()) {
+		return nil, status.Error(codes.Unauthenticated, "token is invalid")
+	}
+
+	return &pb.ValidateTokenResponse{
+		Subject:   session.subject,
+		SessionId: session.id,
+		ExpiresAt: timestamppb.New(session.expiresAt),
+	}, nil
+}
+
+// IntrospectSession реализует pb.AuthServiceServer.
+func (s *Server) IntrospectSession(
+	_ context.Context,
+	req *pb.IntrospectSessionRequest,
+) (*pb.IntrospectSessionResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, err := s.lookup(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &pb.IntrospectSessionResponse{
+		SessionId: session.id,
+		Subject:   session.subject,
+		Active:    session.active(time.Now()),
+		IssuedAt:  timestamppb.New(session.issuedAt),
+		ExpiresAt: timestamppb.New(session.expiresAt),
+	}
+	if !session.revokedAt.IsZero() {
+		resp.RevokedAt = timestamppb.New(session.revokedAt)
+	}
+	return resp, nil
+}
+
+// RevokeSession реализует pb.AuthServiceServer. Повторный отзыв возвращает
+// время первого отзыва.
+func (s *Server) RevokeSession(_ context.Context, req *pb.RevokeSessionRequest) (*pb.RevokeSessionResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, err := s.lookup(req.GetSessionId())
+	if err != nil {
+		return nil, err
+	}
+	if session.revokedAt.IsZero() {
+		session.revokedAt = time.Now()
+	}
+	return &pb.RevokeSessionResponse{RevokedAt: timestamppb.New(session.revokedAt)}, nil
+}
+
+func (s *Server) lookup(sessionID string) (*authSession, error) {
+	if sessionID == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is empty")
+	}
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "session not found")
+	}
+	return session, nil
+}
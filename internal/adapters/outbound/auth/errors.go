@@ -0,0 +1,14 @@
+package auth
+
+import "errors"
+
+var (
+	// ErrEmptyToken возвращается, если токен не передан.
+	ErrEmptyToken = errors.New("auth: token is empty")
+	// ErrInvalidToken сигнализирует о неверной подписи или структуре токена.
+	ErrInvalidToken = errors.New("auth: token is invalid")
+	// ErrEmptySubject означает, что в токене отсутствует идентификатор пользователя.
+	ErrEmptySubject = errors.New("auth: token subject is empty")
+	// ErrUnknownMode возвращается при неизвестном режиме верификатора в конфигурации.
+	ErrUnknownMode = errors.New("auth: unknown verifier mode")
+)
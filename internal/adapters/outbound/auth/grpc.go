@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	pb "github.com/DENFNC/devPractice/gen/go/service/v1"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// ErrServiceUnavailable сигнализирует о недоступности внешнего AuthService.
+// Ошибка оборачивает domain.ErrVerifierUnavailable, по которому шлюз
+// отличает сбой проверки от отклонённого токена.
+var ErrServiceUnavailable = fmt.Errorf("auth: auth service unavailable: %w", domain.ErrVerifierUnavailable)
+
+// GRPCVerifier проверяет токены через внешний AuthService и управляет
+// жизненным циклом gRPC-соединения как компонент контейнера.
+//
+// Пример:
+//
+//	verifier := auth.NewGRPCVerifier(&auth.GRPCVerifierDeps{Cfg: cfg.AuthConfig, Log: log})
+//	if err := verifier.Start(ctx); err != nil {
+//		return err
+//	}
+//	defer verifier.Stop(ctx)
+type GRPCVerifier struct {
+	name   string
+	conn   *grpc.ClientConn
+	client pb.AuthServiceClient
+	deps   *GRPCVerifierDeps
+}
+
+// GRPCVerifierDeps содержит зависимости gRPC-верификатора. DialOptions
+// позволяют подменить транспорт, например на in-memory listener из authtest.
+type GRPCVerifierDeps struct {
+	Cfg         *config.AuthConfig
+	Log         *slog.Logger
+	DialOptions []grpc.DialOption
+}
+
+// NewGRPCVerifier валидирует зависимости и создаёт клиента AuthService.
+// Соединение устанавливается лениво, проверка доступности выполняется в Start.
+func NewGRPCVerifier(deps *GRPCVerifierDeps) *GRPCVerifier {
+	if deps == nil || deps.Cfg == nil {
+		panic("auth config cannot be nil")
+	}
+	if deps.Log == nil {
+		panic("logger cannot be nil")
+	}
+	if deps.Cfg.GRPC.Address == "" {
+		panic("auth service address cannot be empty")
+	}
+
+	creds := credentials.NewClientTLSFromCert(nil, "")
+	if deps.Cfg.GRPC.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	opts := append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, deps.DialOptions...)
+
+	conn, err := grpc.NewClient(deps.Cfg.GRPC.Address, opts...)
+	if err != nil {
+		panic(fmt.Errorf("create auth service client: %w", err))
+	}
+
+	return &GRPCVerifier{
+		name:   "auth-grpc",
+		conn:   conn,
+		client: pb.NewAuthServiceClient(conn),
+		deps:   deps,
+	}
+}
+
+// Name возвращает идентификатор компонента.
+func (v *GRPCVerifier) Name() string { return v.name }
+
+// Start инициирует подключение и ждёт готовности канала либо отмены контекста.
+func (v *GRPCVerifier) Start(ctx context.Context) error {
+	v.conn.Connect()
+	for {
+		state := v.conn.GetState()
+		if state == connectivity.Ready {
+			break
+		}
+		if !v.conn.WaitForStateChange(ctx, state) {
+			v.deps.Log.Debug(
+				"Auth service connection failed",
+				slog.String("address", v.deps.Cfg.GRPC.Address),
+				slog.String("state", state.String()),
+			)
+			return fmt.Errorf("%w: %w", ErrServiceUnavailable, ctx.Err())
+		}
+	}
+
+	v.deps.Log.Debug("Connected to auth service",
+		slog.String("address", v.deps.Cfg.GRPC.Address),
+	)
+	return nil
+}
+
+// Stop закрывает gRPC-соединение.
+func (v *GRPCVerifier) Stop(_ context.Context) error {
+	if err := v.conn.Close(); err != nil {
+		return fmt.Errorf("close auth service connection: %w", err)
+	}
+	return nil
+}
+
+// Verify проверяет токен вызовом AuthService.ValidateToken.
+func (v *GRPCVerifier) Verify(ctx context.Context, token string) (domain.Identity, error) {
+	if token == "" {
+		return domain.Identity{}, ErrEmptyToken
+	}
+
+	if timeout := v.deps.Cfg.GRPC.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	resp, err := v.client.ValidateToken(ctx, &pb.ValidateTokenRequest{Token: token})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unauthenticated, codes.InvalidArgument, codes.PermissionDenied:
+			return domain.Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		default:
+			return domain.Identity{}, fmt.Errorf("%w: %w", ErrServiceUnavailable, err)
+		}
+	}
+	if resp.GetSubject() == "" {
+		return domain.Identity{}, ErrEmptySubject
+	}
+
+	identity := domain.Identity{Subject: resp.GetSubject()}
+	if resp.GetExpiresAt() != nil {
+		identity.ExpiresAt = resp.GetExpiresAt().AsTime()
+	}
+	return identity, nil
+}
@@ -0,0 +1,93 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	pb "github.com/DENFNC/devPractice/gen/go/service/v1"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/auth"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/auth/authtest"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+)
+
+const testSubject = "0199f2a4-5c1e-7d2a-9b7e-3f5c2a1d4e6b"
+
+func newTestVerifier(t *testing.T, srv *authtest.Server) *auth.GRPCVerifier {
+	t.Helper()
+
+	verifier := auth.NewGRPCVerifier(&auth.GRPCVerifierDeps{
+		Cfg: &config.AuthConfig{GRPC: config.AuthGRPCConfig{
+			Address:  authtest.Target,
+			Timeout:  time.Second,
+			Insecure: true,
+		}},
+		Log:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+		DialOptions: srv.DialOptions(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := verifier.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = verifier.Stop(context.Background()) })
+	return verifier
+}
+
+func TestGRPCVerifierVerify(t *testing.T) {
+	srv := authtest.NewServer()
+	defer srv.Close()
+	verifier := newTestVerifier(t, srv)
+
+	valid, _ := srv.IssueToken(testSubject, time.Hour)
+	expired, _ := srv.IssueToken(testSubject, -time.Minute)
+	revoked, sessionID := srv.IssueToken(testSubject, time.Hour)
+	if _, err := srv.RevokeSession(context.Background(), &pb.RevokeSessionRequest{SessionId: sessionID}); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: valid},
+		{name: "empty", token: "", wantErr: auth.ErrEmptyToken},
+		{name: "unknown", token: "unknown-token", wantErr: auth.ErrInvalidToken},
+		{name: "expired", token: expired, wantErr: auth.ErrInvalidToken},
+		{name: "revoked", token: revoked, wantErr: auth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if identity.Subject != testSubject {
+				t.Fatalf("Verify() subject = %q, want %q", identity.Subject, testSubject)
+			}
+			if identity.ExpiresAt.IsZero() {
+				t.Fatal("Verify() expires at is zero")
+			}
+		})
+	}
+}
+
+func TestGRPCVerifierServiceUnavailable(t *testing.T) {
+	srv := authtest.NewServer()
+	verifier := newTestVerifier(t, srv)
+	token, _ := srv.IssueToken(testSubject, time.Hour)
+	srv.Close()
+
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, auth.ErrServiceUnavailable) {
+		t.Fatalf("Verify() error = %v, want %v", err, auth.ErrServiceUnavailable)
+	}
+}
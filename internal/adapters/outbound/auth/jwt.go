@@ -0,0 +1,77 @@
+// Package auth содержит верификаторы токенов доступа, которые используются
+// шлюзом при WebSocket-handshake.
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTVerifier проверяет JWT, подписанные симметричным HMAC-ключом.
+//
+// Пример:
+//
+//	verifier := auth.NewJWTVerifier(cfg.AuthConfig)
+//	identity, err := verifier.Verify(ctx, token)
+type JWTVerifier struct {
+	secret []byte
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier создаёт верификатор по секции конфигурации auth.
+// Паника возникает, если конфигурация или секрет не заданы.
+func NewJWTVerifier(cfg *config.AuthConfig) *JWTVerifier {
+	if cfg == nil || cfg.Secret == "" {
+		panic("jwt secret cannot be empty")
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(),
+			jwt.SigningMethodHS384.Alg(),
+			jwt.SigningMethodHS512.Alg(),
+		}),
+		jwt.WithLeeway(cfg.Leeway),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTVerifier{
+		secret: []byte(cfg.Secret),
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+// Verify проверяет подпись и стандартные claims токена и возвращает
+// идентичность пользователя из claim sub.
+func (v *JWTVerifier) Verify(_ context.Context, token string) (domain.Identity, error) {
+	if token == "" {
+		return domain.Identity{}, ErrEmptyToken
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return v.secret, nil
+	})
+	if err != nil {
+		return domain.Identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	if claims.Subject == "" {
+		return domain.Identity{}, ErrEmptySubject
+	}
+
+	identity := domain.Identity{Subject: claims.Subject}
+	if claims.ExpiresAt != nil {
+		identity.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return identity, nil
+}
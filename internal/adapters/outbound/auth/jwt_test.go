@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTVerifierVerify(t *testing.T) {
+	verifier := NewJWTVerifier(&config.AuthConfig{Secret: testSecret})
+	now := time.Now()
+	valid := jwt.RegisteredClaims{
+		Subject:   "0199f2a4-5c1e-7d2a-9b7e-3f5c2a1d4e6b",
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			token: signToken(t, jwt.SigningMethodHS256, []byte(testSecret), valid),
+		},
+		{
+			name:    "empty",
+			token:   "",
+			wantErr: ErrEmptyToken,
+		},
+		{
+			name: "expired",
+			token: signToken(t, jwt.SigningMethodHS256, []byte(testSecret), jwt.RegisteredClaims{
+				Subject:   valid.Subject,
+				ExpiresAt: jwt.NewNumericDate(now.Add(-time.Hour)),
+			}),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "missing expiration",
+			token: signToken(t, jwt.SigningMethodHS256, []byte(testSecret), jwt.RegisteredClaims{
+				Subject: valid.Subject,
+			}),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "bad signature",
+			token:   signToken(t, jwt.SigningMethodHS256, []byte("other-secret"), valid),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name:    "alg none",
+			token:   signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid),
+			wantErr: ErrInvalidToken,
+		},
+		{
+			name: "empty subject",
+			token: signToken(t, jwt.SigningMethodHS256, []byte(testSecret), jwt.RegisteredClaims{
+				ExpiresAt: valid.ExpiresAt,
+			}),
+			wantErr: ErrEmptySubject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := verifier.Verify(context.Background(), tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && identity.Subject != valid.Subject {
+				t.Fatalf("Verify() subject = %q, want %q", identity.Subject, valid.Subject)
+			}
+		})
+	}
+}
+
+func TestJWTVerifierRejectsForeignAlgorithm(t *testing.T) {
+	verifier := NewJWTVerifier(&config.AuthConfig{Secret: testSecret})
+
+	// Токен подписан HS256, но в заголовке объявлен другой HMAC-алгоритм
+	// вне списка разрешённых.
+	token := signToken(t, &jwt.SigningMethodHMAC{Name: "HS1", Hash: jwt.SigningMethodHS256.Hash}, []byte(testSecret),
+		jwt.RegisteredClaims{
+			Subject:   "0199f2a4-5c1e-7d2a-9b7e-3f5c2a1d4e6b",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		})
+
+	if _, err := verifier.Verify(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestNewJWTVerifierRequiresSecret(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewJWTVerifier() with empty secret did not panic")
+		}
+	}()
+	NewJWTVerifier(&config.AuthConfig{})
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/DENFNC/devPractice/internal/domain"
+)
+
+// StaticVerifier сопоставляет заранее известные токены с идентификаторами
+// пользователей. Предназначен для тестов и локальной разработки.
+//
+// Пример:
+//
+//	verifier := auth.NewStaticVerifier(map[string]string{
+//		"alice-token": "0199f2a4-5c1e-7d2a-9b7e-3f5c2a1d4e6b",
+//	})
+type StaticVerifier struct {
+	tokens map[string]string
+}
+
+// NewStaticVerifier создаёт верификатор с копией переданной таблицы токенов.
+func NewStaticVerifier(tokens map[string]string) *StaticVerifier {
+	copied := make(map[string]string, len(tokens))
+	for token, subject := range tokens {
+		copied[token] = subject
+	}
+	return &StaticVerifier{tokens: copied}
+}
+
+// Verify возвращает идентичность, закреплённую за токеном.
+func (v *StaticVerifier) Verify(_ context.Context, token string) (domain.Identity, error) {
+	if token == "" {
+		return domain.Identity{}, ErrEmptyToken
+	}
+
+	subject, ok := v.tokens[token]
+	if !ok {
+		return domain.Identity{}, fmt.Errorf("%w: unknown static token", ErrInvalidToken)
+	}
+	if subject == "" {
+		return domain.Identity{}, ErrEmptySubject
+	}
+	return domain.Identity{Subject: subject}, nil
+}
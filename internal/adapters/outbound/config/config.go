@@ -15,21 +15,73 @@ import (
 // Config агрегирует все секции конфигурационного файла приложения.
 // Каждое вложенное поле отвечает за конкретный инфраструктурный компонент.
 type Config struct {
-	*AppConfig   `yaml:"app"`
-	*HTTPConfig  `yaml:"http"`
-	*RedisConfig `yaml:"redis"`
-	*KafkaConfig `yaml:"kafka"`
-	RetryConfig  `yaml:"retry"`
+	*AppConfig       `yaml:"app"`
+	*HTTPConfig      `yaml:"http"`
+	*WebSocketConfig `yaml:"ws"`
+	*RedisConfig     `yaml:"redis"`
+	*KafkaConfig     `yaml:"kafka"`
+	*AuthConfig      `yaml:"auth"`
+	*MessageConfig   `yaml:"message"`
+	*OutboxConfig    `yaml:"outbox"`
+	RetryConfig      `yaml:"retry"`
 }
 
-// AppConfig описывает параметры верхнеуровневого приложения
+// AppConfig описывает параметры верхнеуровневого приложения. NodeID
+// идентифицирует экземпляр шлюза при горизонтальном масштабировании; если
+// значение не задано, оно генерируется при запуске. ShutdownTimeout
+// ограничивает время корректной остановки, включая закрытие сессий.
 type AppConfig struct {
+	NodeID          string        `yaml:"node-id"          env:"NODE_ID"`
+	ShutdownTimeout time.Duration `yaml:"shutdown-timeout" env:"SHUTDOWN_TIMEOUT" default:"30s"`
 }
 
 // HTTPConfig хранит настройки HTTP-сервера, включая bind-адрес, который
-// используется адаптерами входящего трафика.
+// используется адаптерами входящего трафика. DebugAddress — отдельный
+// адрес служебного сервера с метриками /debug/vars; пустое значение
+// отключает его. Служебный адрес не должен быть доступен извне.
 type HTTPConfig struct {
-	Address string `yaml:"address"`
+	Address      string `yaml:"address"`
+	DebugAddress string `yaml:"debug-address"`
+}
+
+// WebSocketConfig задаёт параметры WebSocket-сессий. SessionTTL — срок жизни
+// записи сессии и маркера жизни узла в Redis; HeartbeatInterval должен быть
+// заметно меньше SessionTTL, чтобы записи живых соединений не истекали.
+// SweepInterval определяет, как часто узел ищет сессии упавших узлов.
+//
+// SendQueueSize ограничивает очередь исходящих кадров каждой сессии,
+// OverflowPolicy выбирает поведение при её переполнении: "drop_oldest",
+// "drop_newest" или "disconnect". Тем же размером ограничены живые события,
+// отложенные на время возобновления потока. WriteTimeout — дедлайн записи
+// одного кадра.
+//
+// PingInterval задаёт период серверных ping-ов (0 отключает их); после ping
+// клиент должен прислать любой кадр в течение PongTimeout. IdleTimeout —
+// максимальное время без кадров от клиента. PingInterval + PongTimeout не
+// должны превышать IdleTimeout.
+//
+// MaxMessageSize ограничивает размер сообщения клиента в байтах: при
+// превышении соединение закрывается с кодом 1009. CloseTimeout — время
+// ожидания ответного закрывающего кадра клиента.
+//
+// При остановке узла сессии закрываются волнами по DrainWaveSize с паузой
+// DrainWaveInterval; ReconnectJitter ограничивает случайную задержку
+// переподключения, которую шлюз подсказывает клиентам.
+type WebSocketConfig struct {
+	SessionTTL        time.Duration `yaml:"session-ttl"         default:"90s"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat-interval"  default:"30s"`
+	SweepInterval     time.Duration `yaml:"sweep-interval"      default:"60s"`
+	SendQueueSize     int           `yaml:"send-queue-size"     default:"256"`
+	OverflowPolicy    string        `yaml:"overflow-policy"     default:"drop_oldest"`
+	WriteTimeout      time.Duration `yaml:"write-timeout"       default:"10s"`
+	PingInterval      time.Duration `yaml:"ping-interval"       default:"30s"`
+	PongTimeout       time.Duration `yaml:"pong-timeout"        default:"10s"`
+	IdleTimeout       time.Duration `yaml:"idle-timeout"        default:"60s"`
+	MaxMessageSize    int64         `yaml:"max-message-size"    default:"65536"`
+	CloseTimeout      time.Duration `yaml:"close-timeout"       default:"5s"`
+	DrainWaveSize     int           `yaml:"drain-wave-size"     default:"100"`
+	DrainWaveInterval time.Duration `yaml:"drain-wave-interval" default:"200ms"`
+	ReconnectJitter   time.Duration `yaml:"reconnect-jitter"    default:"5s"`
 }
 
 // RedisConfig инкапсулирует параметры подключения к Redis, такие как адрес,
@@ -43,13 +95,171 @@ type RedisConfig struct {
 
 // KafkaConfig содержит настройки брокера Kafka, необходимые для инициализации
 // продюсеров, консьюмеров и управления топиками.
+//
+// Retry задаёт политику повторов для сообщений, обработчик которых вернул
+// ошибку: Attempts — число топиков повторов, Initial/Factor/Max — задержка
+// перед каждой попыткой. TopicRetry переопределяет политику для отдельных
+// топиков. После исчерпания попыток сообщение попадает в "<topic>.dlq".
+//
+// Balancer выбирает партиционер продюсера: "hash" (по умолчанию), "murmur2",
+// "crc32", "round-robin" или "least-bytes". Порядок сообщений одного диалога
+// гарантируют только ключевые балансировщики: hash, murmur2 и crc32.
+//
+// Consumer задаёт параллелизм чтения и пакетную фиксацию оффсетов.
+//
+// Topics сопоставляет логические имена топиков ("messages", "receipts",
+// "presence", "notifications") с реальными топиками Kafka. Код публикует и
+// подписывается по логическим именам; ключи TopicRetry — реальные топики.
+// Чтение нескольких топиков требует GroupID. TestTopic устарел: он
+// используется как топик "messages", если тот не задан в Topics, и
+// будет удалён — перенесите значение в topics.messages.
+//
+// Producer настраивает пакетную запись, подтверждения и сжатие продюсера.
+//
+// TLS и SASL задают защиту и аутентификацию соединений с брокером; они
+// применяются одинаково к проверке соединения, читателям и продюсеру.
 type KafkaConfig struct {
-	Address       string      `yaml:"address"`
-	TestTopic     string      `yaml:"test-topic"`
-	GroupID       string      `yaml:"group-id"`
-	Network       string      `yaml:"network"`
-	FetchBackoff  RetryConfig `yaml:"fetchBackoff"`
-	CommitBackoff RetryConfig `yaml:"commitBackoff"`
+	Address       string                 `yaml:"address"`
+	Topics        map[string]string      `yaml:"topics"`
+	TestTopic     string                 `yaml:"test-topic"`
+	GroupID       string                 `yaml:"group-id"`
+	Network       string                 `yaml:"network"`
+	Balancer      string                 `yaml:"balancer" default:"hash"`
+	FetchBackoff  RetryConfig            `yaml:"fetchBackoff"`
+	CommitBackoff RetryConfig            `yaml:"commitBackoff"`
+	Retry         RetryConfig            `yaml:"retry"`
+	TopicRetry    map[string]RetryConfig `yaml:"topic-retry"`
+	Consumer      ConsumerConfig         `yaml:"consumer"`
+	Producer      ProducerConfig         `yaml:"producer"`
+	TLS           KafkaTLSConfig         `yaml:"tls"`
+	SASL          KafkaSASLConfig        `yaml:"sasl"`
+}
+
+// ProducerConfig настраивает продюсер Kafka. BatchSize и BatchTimeout
+// ограничивают пачку: она отправляется при наборе BatchSize сообщений или
+// по истечении BatchTimeout. RequiredAcks: "all", "one" или "none".
+// Compression: "none", "gzip", "snappy", "lz4" или "zstd". Async включает
+// асинхронную запись: публикация не ждёт брокера, а ошибки записи
+// приходят в обратный вызов и не попадают в ответ клиенту; такие сообщения
+// перекладываются в outbox, а без него с них снимается ключ идемпотентности.
+// AutoCreateTopics разрешает продюсеру создавать отсутствующие топики; по
+// умолчанию выключен, и топики, включая топики повторов и DLQ, создаются
+// заранее.
+type ProducerConfig struct {
+	BatchSize        int           `yaml:"batch-size"         default:"100"`
+	BatchTimeout     time.Duration `yaml:"batch-timeout"      default:"10ms"`
+	RequiredAcks     string        `yaml:"required-acks"      default:"all"`
+	Compression      string        `yaml:"compression"        default:"none"`
+	Async            bool          `yaml:"async"`
+	AutoCreateTopics bool          `yaml:"auto-create-topics"`
+}
+
+// KafkaTLSConfig включает TLS для соединений с Kafka. CAFile — PEM с
+// корневыми сертификатами брокеров (по умолчанию системные), CertFile и
+// KeyFile — клиентский сертификат для mTLS. InsecureSkipVerify отключает
+// проверку сертификата брокера и допустим только для локальной разработки.
+type KafkaTLSConfig struct {
+	Enabled            bool   `yaml:"enabled"              env:"KAFKA_TLS_ENABLED"`
+	CAFile             string `yaml:"ca-file"`
+	CertFile           string `yaml:"cert-file"`
+	KeyFile            string `yaml:"key-file"`
+	ServerName         string `yaml:"server-name"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify"`
+}
+
+// KafkaSASLConfig задаёт SASL-аутентификацию. Mechanism: "" (без SASL),
+// "plain", "scram-sha-256" или "scram-sha-512". Пароль удобнее передавать
+// через переменную окружения KAFKA_SASL_PASSWORD.
+type KafkaSASLConfig struct {
+	Mechanism string `yaml:"mechanism" env:"KAFKA_SASL_MECHANISM"`
+	Username  string `yaml:"username"  env:"KAFKA_SASL_USERNAME"`
+	Password  string `yaml:"password"  env:"KAFKA_SASL_PASSWORD"`
+}
+
+// ConsumerConfig управляет конкурентным чтением Kafka. Каждая партиция
+// обрабатывается WorkersPerPartition воркерами: при значении 1 порядок
+// сохраняется для всей партиции, при большем сообщения распределяются по
+// хешу ключа и порядок сохраняется для ключа. QueueSize — размер очереди
+// каждого воркера. Оффсеты фиксируются раз в CommitInterval или после
+// CommitBatch обработанных сообщений, но только до последнего сообщения,
+// перед которым обработаны все прочитанные ранее.
+type ConsumerConfig struct {
+	WorkersPerPartition int           `yaml:"workers-per-partition" default:"1"`
+	QueueSize           int           `yaml:"queue-size"            default:"64"`
+	CommitInterval      time.Duration `yaml:"commit-interval"       default:"1s"`
+	CommitBatch         int           `yaml:"commit-batch"          default:"100"`
+}
+
+// AuthConfig описывает параметры проверки токенов при WebSocket-handshake.
+// Mode выбирает верификатор: "jwt" проверяет HMAC-подпись токена секретом
+// Secret, "grpc" делегирует проверку внешнему AuthService, "static"
+// сопоставляет токены из StaticTokens с идентификаторами пользователей и
+// предназначен для тестов и локальной разработки. Секрета по умолчанию нет:
+// его передают через AUTH_SECRET, и без него шлюз в режиме "jwt" не
+// запускается.
+type AuthConfig struct {
+	Mode         string            `yaml:"mode"          default:"jwt"`
+	Secret       string            `yaml:"secret"        env:"AUTH_SECRET"`
+	Issuer       string            `yaml:"issuer"`
+	Audience     string            `yaml:"audience"`
+	Leeway       time.Duration     `yaml:"leeway"        default:"30s"`
+	StaticTokens map[string]string `yaml:"static-tokens"`
+	GRPC         AuthGRPCConfig    `yaml:"grpc"`
+}
+
+// AuthGRPCConfig содержит параметры подключения к внешнему AuthService.
+// По умолчанию соединение защищено TLS; Insecure включает plaintext и
+// допустим только для локальной разработки.
+type AuthGRPCConfig struct {
+	Address  string        `yaml:"address"`
+	Timeout  time.Duration `yaml:"timeout"  default:"2s"`
+	Insecure bool          `yaml:"insecure" default:"false"`
+}
+
+// MessageConfig задаёт параметры обработки сообщений чата. IdempotencyTTL —
+// сколько хранится ключ (отправитель, client_req_id), в течение которого
+// повторная отправка возвращает исходный идентификатор сообщения. DedupeTTL —
+// сколько хранится метка доставки, отсекающая повторные доставки из Kafka.
+//
+// Сообщения пользователям без активных сессий копятся в почтовом ящике:
+// не больше InboxMaxLen последних сообщений, ящик удаляется, если
+// пользователь не подключался дольше InboxTTL.
+//
+// Доставленные события хранятся в потоке пользователя для возобновления
+// после переподключения: примерно StreamMaxLen последних событий, поток
+// удаляется, если в него не писали дольше StreamTTL. Повтор пропущенного
+// ждёт места в очереди отправки сессии и не вытесняет другие кадры.
+//
+// Состояние квитанций о получении и прочтении хранится ReceiptTTL с
+// последней квитанции диалога. Участники каждого сообщения, по которым
+// проверяются квитанции, хранятся ParticipantsTTL с отправки: квитанции на
+// более старые сообщения игнорируются.
+type MessageConfig struct {
+	IdempotencyTTL  time.Duration `yaml:"idempotency-ttl"  default:"24h"`
+	DedupeTTL       time.Duration `yaml:"dedupe-ttl"       default:"1h"`
+	InboxMaxLen     int           `yaml:"inbox-max-len"    default:"256"`
+	InboxTTL        time.Duration `yaml:"inbox-ttl"        default:"168h"`
+	StreamMaxLen    int           `yaml:"stream-max-len"   default:"200"`
+	StreamTTL       time.Duration `yaml:"stream-ttl"       default:"24h"`
+	ReceiptTTL      time.Duration `yaml:"receipt-ttl"      default:"720h"`
+	ParticipantsTTL time.Duration `yaml:"participants-ttl" default:"72h"`
+}
+
+// OutboxConfig задаёт локальную очередь сообщений, которые не удалось
+// опубликовать в Kafka. Path — путь к append-only файлу очереди; рядом
+// хранится курсор "<path>.cursor". RelayBackoff — пауза между попытками
+// публикации, пока брокер недоступен: такие записи повторяются без
+// ограничения попыток. Записи, которые брокер не примет никогда, например
+// слишком большие, переносятся в "<path>.rejected".
+//
+// MaxBytes ограничивает размер файла очереди: когда он достигнут, новые
+// сообщения отклоняются, пока ретранслятор не опустошит очередь. 0 снимает
+// ограничение.
+type OutboxConfig struct {
+	Enabled      bool        `yaml:"enabled"       default:"true"`
+	Path         string      `yaml:"path"          default:"data/outbox.log"`
+	MaxBytes     int64       `yaml:"max-bytes"     default:"67108864"`
+	RelayBackoff RetryConfig `yaml:"relay-backoff"`
 }
 
 // RetryConfig определяет параметры для механизма повторных попыток.
@@ -63,7 +273,8 @@ type RetryConfig struct {
 
 // LoadConfig читает конфигурационный YAML-файл и возвращает агрегированную
 // структуру Config. Функция завершит работу приложения с логированием ошибки,
-// если файл отсутствует, недоступен или содержит некорректные данные.
+// если файл отсутствует, недоступен, содержит некорректные данные или не
+// проходит Validate.
 func LoadConfig(path string) *Config {
 	info, err := os.Stat(path)
 	if os.IsNotExist(err) || info.IsDir() {
@@ -74,6 +285,9 @@ func LoadConfig(path string) *Config {
 	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
 		log.Fatalf("Error reading config: %v", err)
 	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
 
 	return &cfg
 }
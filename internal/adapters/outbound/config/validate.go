@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidConfig возвращается Validate для несогласованных значений.
+var ErrInvalidConfig = errors.New("config: invalid value")
+
+// Допустимые значения ws.overflow-policy.
+const (
+	OverflowDropOldest = "drop_oldest"
+	OverflowDropNewest = "drop_newest"
+	OverflowDisconnect = "disconnect"
+)
+
+// ValidOverflowPolicy сообщает, известна ли политика переполнения очереди
+// отправки.
+func ValidOverflowPolicy(policy string) bool {
+	switch policy {
+	case OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+		return true
+	default:
+		return false
+	}
+}
+
+// Validate проверяет значения, ошибка в которых иначе проявилась бы только
+// во время работы. Отсутствующие секции не проверяются.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if ws := c.WebSocketConfig; ws != nil {
+		if !ValidOverflowPolicy(ws.OverflowPolicy) {
+			errs = append(errs, fmt.Errorf("%w: ws.overflow-policy %q", ErrInvalidConfig, ws.OverflowPolicy))
+		}
+		if ws.PingInterval > 0 && ws.PingInterval+ws.PongTimeout > ws.IdleTimeout {
+			errs = append(errs, fmt.Errorf("%w: ws.ping-interval %s + ws.pong-timeout %s exceed ws.idle-timeout %s",
+				ErrInvalidConfig, ws.PingInterval, ws.PongTimeout, ws.IdleTimeout))
+		}
+		if ws.HeartbeatInterval >= ws.SessionTTL {
+			errs = append(errs, fmt.Errorf("%w: ws.heartbeat-interval %s must be less than ws.session-ttl %s",
+				ErrInvalidConfig, ws.HeartbeatInterval, ws.SessionTTL))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -5,12 +5,19 @@ import (
 	"github.com/segmentio/kafka-go"
 )
 
-// createReader возвращает подготовленный kafka.Reader для заданных адреса, топика и группы.
-func createReader(address, topic, groupID string) *kafka.Reader {
-	r := kafka.NewReader(kafka.ReaderConfig{
+// createReader возвращает подготовленный kafka.Reader для заданных адреса,
+// группы и топиков. Несколько топиков читаются только в составе группы.
+// dialer задаёт TLS и SASL соединений читателя.
+func createReader(address, groupID string, dialer *kafka.Dialer, topics ...string) *kafka.Reader {
+	cfg := kafka.ReaderConfig{
 		Brokers: []string{address},
-		Topic:   topic,
 		GroupID: groupID,
-	})
-	return r
+		Dialer:  dialer,
+	}
+	if len(topics) == 1 {
+		cfg.Topic = topics[0]
+	} else {
+		cfg.GroupTopics = topics
+	}
+	return kafka.NewReader(cfg)
 }
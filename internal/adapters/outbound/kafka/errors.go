@@ -11,4 +11,16 @@ var (
 	ErrFetchMessage = errors.New("kafka: fetch message failed")
 	// ErrCommitMessage означает ошибку подтверждения оффсета.
 	ErrCommitMessage = errors.New("kafka: commit message failed")
+	// ErrUnknownBalancer возвращается для неизвестного имени балансировщика.
+	ErrUnknownBalancer = errors.New("kafka: unknown balancer")
+	// ErrUnknownRequiredAcks возвращается для неизвестного уровня подтверждений.
+	ErrUnknownRequiredAcks = errors.New("kafka: unknown required acks")
+	// ErrUnknownCompression возвращается для неизвестного кодека сжатия.
+	ErrUnknownCompression = errors.New("kafka: unknown compression codec")
+	// ErrUnknownTopic возвращается для имени топика, которого нет в конфигурации.
+	ErrUnknownTopic = errors.New("kafka: unknown topic")
+	// ErrHandlerPanic означает панику в обработчике сообщения.
+	ErrHandlerPanic = errors.New("kafka: handler panicked")
+	// ErrSecurityConfig сообщает о некорректных настройках TLS или SASL.
+	ErrSecurityConfig = errors.New("kafka: invalid security config")
 )
@@ -0,0 +1,187 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/segmentio/kafka-go"
+)
+
+// Record — сообщение топика вместе с его координатами в Kafka.
+type Record struct {
+	events.Message
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+// ScanRange ограничивает чтение топика. Partition равный -1 означает все
+// партиции. Если задан Since, начальный оффсет определяется по времени,
+// иначе используется FromOffset (отрицательный — с самого раннего).
+// Чтение партиции завершается на ToOffset (включительно, отрицательный — без
+// ограничения), на первом сообщении позже Until или на последнем оффсете,
+// существовавшем в момент начала чтения.
+type ScanRange struct {
+	Partition  int
+	FromOffset int64
+	ToOffset   int64
+	Since      time.Time
+	Until      time.Time
+}
+
+// Scan читает topic без участия в consumer group и передаёт записи в fn в
+// порядке оффсетов каждой партиции. Ошибка fn прерывает чтение.
+func (k *Kafka) Scan(ctx context.Context, topic string, rng ScanRange, fn func(Record) error) error {
+	partitions, err := k.partitions(ctx, topic)
+	if err != nil {
+		return err
+	}
+
+	for _, partition := range partitions {
+		if rng.Partition >= 0 && partition != rng.Partition {
+			continue
+		}
+		if err := k.scanPartition(ctx, topic, partition, rng, fn); err != nil {
+			return fmt.Errorf("scan %s/%d: %w", topic, partition, err)
+		}
+	}
+	return nil
+}
+
+// Publish публикует сообщение в топик msg.Topic с сохранением ключа и
+// заголовков. Используется для повторной публикации записей, поэтому пишет
+// синхронно даже в асинхронном режиме продюсера. Ошибки, которые не
+// исправит повтор, оборачивают events.ErrUndeliverable.
+func (k *Kafka) Publish(ctx context.Context, msg events.Message) error {
+	if msg.Topic == "" {
+		return fmt.Errorf("%w: publish: topic is empty", events.ErrUndeliverable)
+	}
+
+	err := k.write(ctx, k.reliable, msg)
+	if err != nil && undeliverable(err) {
+		return fmt.Errorf("%w: %w", events.ErrUndeliverable, err)
+	}
+	return err
+}
+
+// undeliverable сообщает, что брокер отклонил сообщение по причине, которая
+// не исчезнет при повторе.
+func undeliverable(err error) bool {
+	if errors.Is(err, ErrUnknownTopic) {
+		return true
+	}
+	var tooLarge kafka.MessageTooLargeError
+	if errors.As(err, &tooLarge) {
+		return true
+	}
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		for _, writeErr := range writeErrs {
+			if writeErr != nil && undeliverable(writeErr) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, code := range []kafka.Error{kafka.MessageSizeTooLarge, kafka.InvalidTopic, kafka.RecordListTooLarge, kafka.InvalidRecord} {
+		if errors.Is(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *Kafka) partitions(ctx context.Context, topic string) ([]int, error) {
+	conn, err := k.dialer.DialContext(ctx, k.deps.Cfg.Network, k.deps.Cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("dial kafka broker: %w", err)
+	}
+	defer conn.Close()
+
+	meta, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("read partitions of %s: %w", topic, err)
+	}
+
+	partitions := make([]int, 0, len(meta))
+	for _, p := range meta {
+		partitions = append(partitions, p.ID)
+	}
+	return partitions, nil
+}
+
+// offsets определяет диапазон [first, last) партиции согласно rng.
+func (k *Kafka) offsets(ctx context.Context, topic string, partition int, rng ScanRange) (int64, int64, error) {
+	conn, err := k.dialer.DialLeader(ctx, k.deps.Cfg.Network, k.deps.Cfg.Address, topic, partition)
+	if err != nil {
+		return 0, 0, fmt.Errorf("dial partition leader: %w", err)
+	}
+	defer conn.Close()
+
+	first, last, err := conn.ReadOffsets()
+	if err != nil {
+		return 0, 0, fmt.Errorf("read offsets: %w", err)
+	}
+
+	switch {
+	case !rng.Since.IsZero():
+		first, err = conn.ReadOffset(rng.Since)
+		if err != nil {
+			return 0, 0, fmt.Errorf("read offset at %s: %w", rng.Since.Format(time.RFC3339), err)
+		}
+	case rng.FromOffset > first:
+		first = rng.FromOffset
+	}
+	if rng.ToOffset >= 0 && rng.ToOffset+1 < last {
+		last = rng.ToOffset + 1
+	}
+	return first, last, nil
+}
+
+func (k *Kafka) scanPartition(ctx context.Context, topic string, partition int, rng ScanRange, fn func(Record) error) error {
+	first, last, err := k.offsets(ctx, topic, partition, rng)
+	if err != nil {
+		return err
+	}
+	if first >= last {
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   []string{k.deps.Cfg.Address},
+		Topic:     topic,
+		Partition: partition,
+		Dialer:    k.dialer,
+	})
+	defer reader.Close()
+
+	if err := reader.SetOffset(first); err != nil {
+		return fmt.Errorf("set offset %d: %w", first, err)
+	}
+
+	for {
+		msg, err := reader.ReadMessage(ctx)
+		if err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+		if !rng.Until.IsZero() && msg.Time.After(rng.Until) {
+			return nil
+		}
+
+		record := Record{
+			Message:   toEventMessage(msg),
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Time:      msg.Time,
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+		if msg.Offset+1 >= last {
+			return nil
+		}
+	}
+}
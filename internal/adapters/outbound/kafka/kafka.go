@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 
 	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
 	"github.com/DENFNC/devPractice/pkg/retry"
 	"github.com/segmentio/kafka-go"
 )
@@ -23,26 +25,33 @@ import (
 //	}
 //	defer kfk.Stop(ctx)
 //
-//	kfk.AddDeliveryHandler(func(ctx context.Context, payload []byte) error {
-//		var msg Message
-//		return json.Unmarshal(payload, &msg)
+//	kfk.Handle(events.TopicMessages, func(ctx context.Context, msg events.Message) error {
+//		return nil
 //	})
 //	go kfk.StartConsuming(ctx)
 //
-//	if err := kfk.WriteMessage(ctx, []byte(`"ping"`)); err != nil {
+//	msg := events.Message{Topic: events.TopicMessages, Value: []byte(`"ping"`)}
+//	if err := kfk.WriteMessage(ctx, msg); err != nil {
 //		return err
 //	}
 //
-// Компонент потокобезопасен в части основных операций, однако зарегистрированные
-// обработчики выполняются последовательно в порядке добавления.
+// Компонент потокобезопасен в части основных операций. Обработчики сообщений
+// разных партиций выполняются параллельно, поэтому должны быть
+// потокобезопасными.
 type Kafka struct {
-	name     string
-	router   *Router
-	consumer *kafka.Reader
-	producer *kafka.Writer
-	deps     *KafkaDeps
+	name         string
+	router       *Router
+	dialer       *kafka.Dialer
+	producer     *kafka.Writer
+	reliable     *kafka.Writer
+	onCompletion CompletionHandler
+	deps         *KafkaDeps
 }
 
+// CompletionHandler получает результат записи пачки в асинхронном режиме
+// продюсера (KafkaConfig.Producer.Async); err равен nil при успешной записи.
+type CompletionHandler func(messages []events.Message, err error)
+
 // KafkaDeps содержит зависимости рантайма для Kafka-адаптера: логгер и
 // конфигурацию подключения к брокеру.
 //
@@ -91,16 +100,33 @@ func NewKafka(deps *KafkaDeps) *Kafka {
 //	fmt.Println(kfk.Name()) // kafka
 func (k *Kafka) Name() string { return k.name }
 
-// Handle регистрирует обработчик событий для указанного топика Kafka.
-func (k *Kafka) Handle(topic string, handler Handler) {
+// Handle регистрирует обработчик событий для топика с логическим именем name
+// (см. config.KafkaConfig.Topics). StartConsuming подписывается на все
+// топики с обработчиками. Паника возникает, если топик не настроен.
+func (k *Kafka) Handle(name string, handler Handler) {
 	if handler == nil {
 		panic("kafka handler cannot be nil")
 	}
+	topic, ok := k.logicalTopic(name)
+	if !ok {
+		panic(fmt.Sprintf("kafka topic %q is not configured", name))
+	}
 	k.router.Handle(topic, handler)
 }
 
+// HandleCompletion регистрирует обработчик результатов асинхронной записи.
+// В асинхронном режиме WriteMessage не ждёт брокера, поэтому сообщения,
+// запись которых не удалась, можно обработать только здесь. Регистрируется
+// до первой публикации.
+func (k *Kafka) HandleCompletion(handler CompletionHandler) {
+	k.onCompletion = handler
+}
+
 // Start устанавливает соединение с брокером (health-check), инициализирует
-// консюмера и продюсера и логирует параметры подключения.
+// продюсера и логирует параметры подключения. Настройки TLS и SASL из
+// конфигурации применяются ко всем соединениям: проверке, чтению и
+// записи. Читатели создаются в StartConsuming, когда известны топики с
+// обработчиками.
 //
 // Пример:
 //
@@ -108,7 +134,17 @@ func (k *Kafka) Handle(topic string, handler Handler) {
 //		return fmt.Errorf("kafka start: %w", err)
 //	}
 func (k *Kafka) Start(ctx context.Context) error {
-	if err := ensureKafkaConnection(ctx, k.deps.Cfg.Network, k.deps.Cfg.Address); err != nil {
+	dialer, err := newDialer(k.deps.Cfg)
+	if err != nil {
+		return err
+	}
+	transport, err := newTransport(k.deps.Cfg)
+	if err != nil {
+		return err
+	}
+	k.dialer = dialer
+
+	if err := ensureKafkaConnection(ctx, dialer, k.deps.Cfg.Network, k.deps.Cfg.Address); err != nil {
 		k.deps.Log.Debug(
 			"Kafka connection failed",
 			slog.String("network", k.deps.Cfg.Network),
@@ -118,22 +154,42 @@ func (k *Kafka) Start(ctx context.Context) error {
 		return fmt.Errorf("%w: %w", ErrEnsureConnection, err)
 	}
 
-	k.consumer = createReader(k.deps.Cfg.Address, k.deps.Cfg.TestTopic, k.deps.Cfg.GroupID)
-	k.producer = createWriter(k.deps.Cfg.Address, k.deps.Cfg.TestTopic)
+	opts, err := producerOptions(k.deps.Cfg)
+	if err != nil {
+		return err
+	}
+	k.producer = createWriter(k.deps.Cfg.Address, transport, opts)
+	k.reliable = k.producer
+	if opts.async {
+		k.producer.Completion = k.complete
+		// Повторы, DLQ и повторная публикация должны знать результат записи
+		// до коммита оффсета, поэтому пишут синхронно.
+		opts.async = false
+		k.reliable = createWriter(k.deps.Cfg.Address, transport, opts)
+	}
 
 	k.deps.Log.Debug(
 		"Connected to Kafka",
 		slog.String("network", k.deps.Cfg.Network),
 		slog.String("address", k.deps.Cfg.Address),
 		slog.String("group_id", k.deps.Cfg.GroupID),
-		slog.String("topic", k.deps.Cfg.TestTopic),
+		slog.Any("topics", k.deps.Cfg.Topics),
+		slog.Bool("tls", k.deps.Cfg.TLS.Enabled),
+		slog.String("sasl", k.deps.Cfg.SASL.Mechanism),
+		slog.Bool("async", k.deps.Cfg.Producer.Async),
 	)
+	if k.deps.Cfg.TestTopic != "" {
+		k.deps.Log.Warn("kafka.test-topic is deprecated, use kafka.topics.messages",
+			slog.String("test_topic", k.deps.Cfg.TestTopic))
+	}
 
 	return nil
 }
 
-// Stop корректно закрывает соединения консюмера и продюсера,
-// логируя ошибки закрытия при их возникновении.
+// Stop корректно закрывает продюсеров, логируя ошибки закрытия. Сбой
+// закрытия одного продюсера не мешает закрыть другой; ошибки объединяются.
+// В асинхронном режиме Close дожидается записи накопленных сообщений.
+// Читатели закрывает StartConsuming при отмене контекста.
 //
 // Пример:
 //
@@ -143,45 +199,42 @@ func (k *Kafka) Start(ctx context.Context) error {
 //		}
 //	}()
 func (k *Kafka) Stop(_ context.Context) error {
-	if k.consumer != nil {
-		if err := k.consumer.Close(); err != nil {
-			k.deps.Log.Error(
-				"Failed to close Kafka consumer connection",
-				slog.String("address", k.deps.Cfg.Address),
-				slog.String("group_id", k.deps.Cfg.GroupID),
-				slog.String("topic", k.deps.Cfg.TestTopic),
-				slog.String("error", err.Error()),
-			)
-			return fmt.Errorf("close kafka consumer: %w", err)
-		}
+	var errs []error
+	if k.reliable != nil && k.reliable != k.producer {
+		errs = append(errs, k.closeWriter(k.reliable))
 	}
-
 	if k.producer != nil {
-		if err := k.producer.Close(); err != nil {
-			k.deps.Log.Error(
-				"Failed to close Kafka producer connection",
-				slog.String("address", k.deps.Cfg.Address),
-				slog.String("topic", k.deps.Cfg.TestTopic),
-				slog.String("error", err.Error()),
-			)
-			return fmt.Errorf("close kafka producer: %w", err)
-		}
+		errs = append(errs, k.closeWriter(k.producer))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
 	}
 
 	k.deps.Log.Debug(
 		"Kafka connections closed",
 		slog.String("address", k.deps.Cfg.Address),
 		slog.String("group_id", k.deps.Cfg.GroupID),
-		slog.String("topic", k.deps.Cfg.TestTopic),
 	)
 	return nil
 }
 
+func (k *Kafka) closeWriter(writer *kafka.Writer) error {
+	if err := writer.Close(); err != nil {
+		k.deps.Log.Error(
+			"Failed to close Kafka producer connection",
+			slog.String("address", k.deps.Cfg.Address),
+			slog.String("error", err.Error()),
+		)
+		return fmt.Errorf("close kafka producer: %w", err)
+	}
+	return nil
+}
+
 // ensureKafkaConnection выполняет проверку доступности брокера:
 // открывает и закрывает TCP-соединение к адресу Kafka.
 // Не экспортируется намеренно.
-func ensureKafkaConnection(ctx context.Context, network, address string) error {
-	conn, err := kafka.DialContext(ctx, network, address)
+func ensureKafkaConnection(ctx context.Context, dialer *kafka.Dialer, network, address string) error {
+	conn, err := dialer.DialContext(ctx, network, address)
 	if err != nil {
 		return fmt.Errorf("dial kafka broker %s://%s: %w", network, address, err)
 	}
@@ -191,20 +244,43 @@ func ensureKafkaConnection(ctx context.Context, network, address string) error {
 	return nil
 }
 
-// WriteMessage публикует одно сообщение в настроенный Kafka-топик.
+// WriteMessage публикует сообщение в топик msg.Topic. Логическое имя из
+// конфигурации (например, events.TopicMessages) заменяется именем топика,
+// пустое означает events.TopicMessages, неизвестные имена отклоняются
+// (см. Topic). Сообщения с одинаковым ключом попадают в одну
+// партицию при ключевом балансировщике, что сохраняет их порядок.
 // Возвращает ошибку с обёрткой при сбое записи.
 //
+// В асинхронном режиме продюсера (KafkaConfig.Producer.Async) метод только
+// ставит сообщение в пачку и не возвращает ошибок записи: они логируются и
+// передаются обработчику HandleCompletion.
+//
 // Пример:
 //
-//	payload := []byte(`{"id":"123","event":"created"}`)
-//	if err := kfk.WriteMessage(ctx, payload); err != nil {
+//	msg := events.Message{
+//		Topic: events.TopicMessages,
+//		Key:   []byte("conversation-1"),
+//		Value: []byte(`{"id":"123"}`),
+//	}
+//	if err := kfk.WriteMessage(ctx, msg); err != nil {
 //		return fmt.Errorf("publish: %w", err)
 //	}
-func (k *Kafka) WriteMessage(ctx context.Context, msg []byte) error {
-	err := k.producer.WriteMessages(ctx,
+func (k *Kafka) WriteMessage(ctx context.Context, msg events.Message) error {
+	return k.write(ctx, k.producer, msg)
+}
+
+func (k *Kafka) write(ctx context.Context, writer *kafka.Writer, msg events.Message) error {
+	topic, err := k.Topic(msg.Topic)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWriteMessage, err)
+	}
+
+	err = writer.WriteMessages(ctx,
 		kafka.Message{
-			Key:   nil,
-			Value: msg,
+			Topic:   topic,
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: mapToHeaders(msg.Headers),
 		},
 	)
 	if err != nil {
@@ -214,10 +290,22 @@ func (k *Kafka) WriteMessage(ctx context.Context, msg []byte) error {
 	return nil
 }
 
-// StartConsuming запускает непрерывное чтение сообщений из топика с коммитом
-// оффсетов. Останавливается при отмене контекста. При временных ошибках чтения
-// делает паузы и продолжает работу, используя стратегии повторных попыток,
-// заданные в конфигурации.
+// StartConsuming запускает непрерывное чтение сообщений из всех топиков, для
+// которых зарегистрирован обработчик, с коммитом оффсетов. Останавливается
+// при отмене контекста. При временных ошибках чтения делает паузы и
+// продолжает работу, используя стратегии повторных попыток, заданные в
+// конфигурации.
+//
+// Сообщение, обработчик которого вернул ошибку, публикуется в топик повторов
+// "<topic>.retry.<n>" с задержкой по политике топика, а после исчерпания
+// попыток — в "<topic>.dlq". Исходный оффсет коммитится только после
+// успешной публикации. Все топики повторов читает один отдельный читатель,
+// чтобы ожидание задержки не блокировало основной поток, а группа не
+// ребалансировалась при входе читателя на каждый топик.
+//
+// Сообщения обрабатываются параллельно воркерами партиций с сохранением
+// порядка внутри партиции (или внутри ключа, если на партицию выделено
+// несколько воркеров), см. config.ConsumerConfig.
 //
 // Пример:
 //
@@ -226,12 +314,85 @@ func (k *Kafka) WriteMessage(ctx context.Context, msg []byte) error {
 //	}()
 //	// ... позже cancel() остановит цикл чтения и метод завершится.
 func (k *Kafka) StartConsuming(ctx context.Context) {
+	topics := k.router.Topics()
+	if len(topics) == 0 {
+		k.deps.Log.Warn("Kafka consumer has no topics to subscribe")
+		return
+	}
+	if len(topics) > 1 && k.deps.Cfg.GroupID == "" {
+		k.deps.Log.Error("Kafka consumer requires group-id to subscribe to several topics",
+			slog.Any("topics", topics))
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, reader := range k.retryReaders() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			k.consume(ctx, reader, true)
+		}()
+	}
+
+	k.deps.Log.Debug("Kafka consumer subscribed", slog.Any("topics", topics))
+	k.consume(ctx, createReader(k.deps.Cfg.Address, k.deps.Cfg.GroupID, k.dialer, topics...), false)
+	wg.Wait()
+}
+
+// retryReaders создаёт читателей топиков повторов: один на все топики в
+// группе потребителей либо, без GroupID, по одному на топик — GroupTopics
+// без группы не поддерживается.
+func (k *Kafka) retryReaders() []*kafka.Reader {
+	topics := k.retryTopics()
+	if len(topics) == 0 {
+		return nil
+	}
+	if k.deps.Cfg.GroupID != "" {
+		return []*kafka.Reader{createReader(k.deps.Cfg.Address, k.deps.Cfg.GroupID, k.dialer, topics...)}
+	}
+
+	readers := make([]*kafka.Reader, 0, len(topics))
+	for _, topic := range topics {
+		readers = append(readers, createReader(k.deps.Cfg.Address, "", k.dialer, topic))
+	}
+	return readers
+}
+
+// consume читает сообщения reader до отмены контекста и раздаёт их воркерам
+// партиций. delayed включает ожидание HeaderNotBefore для топиков повторов.
+// Оффсеты фиксируются пачками и только до последнего сообщения, перед
+// которым обработаны все прочитанные ранее. При остановке consume дожидается
+// воркеров и фиксирует обработанное.
+func (k *Kafka) consume(ctx context.Context, reader *kafka.Reader, delayed bool) {
 	defer func() {
-		if err := k.consumer.Close(); err != nil {
+		if err := reader.Close(); err != nil {
 			k.deps.Log.Warn("consumer close failed", "err", err)
 		}
 	}() // безопасное закрытие
 
+	settings := consumerSettings(&k.deps.Cfg.Consumer)
+	tracker := newOffsetTracker(settings.CommitBatch)
+	pool := newWorkerPool(settings, func(msg trackedMessage) {
+		if k.process(ctx, msg.Message, delayed) {
+			tracker.complete(msg)
+		}
+	}, tracker.forget)
+
+	committerDone := make(chan struct{})
+	go func() {
+		defer close(committerDone)
+		k.commitLoop(ctx, reader, tracker, settings.CommitInterval)
+	}()
+
+	defer func() {
+		pool.close()
+		<-committerDone
+
+		commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalCommitTimeout)
+		defer cancel()
+		k.commit(commitCtx, reader, tracker)
+	}()
+
 	backoff := retry.NewBackoff(&k.deps.Cfg.FetchBackoff)
 
 	for {
@@ -240,7 +401,7 @@ func (k *Kafka) StartConsuming(ctx context.Context) {
 			return
 		}
 
-		msg, err := k.fetch(ctx)
+		msg, err := k.fetch(ctx, reader)
 		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				k.deps.Log.Debug("Kafka consumer context canceled")
@@ -252,29 +413,68 @@ func (k *Kafka) StartConsuming(ctx context.Context) {
 		}
 		backoff.Reset()
 
-		if err := k.router.Dispatch(ctx, msg); err != nil {
-			// Если обработка упала — НЕ коммитим, чтобы переиграть позже.
-			k.deps.Log.Error("handler failed", "err", err, "topic", msg.Topic, "offset", msg.Offset)
-			//! либо ретраим локально с ограничением, либо отдаем в DLQ.
-			//! if dlqErr := k.toDLQ(ctx, msg, err); dlqErr != nil {
-			//! 	k.deps.Log.Error("dlq failed", "err", dlqErr)
-			//! 	// Без DLQ оставляем без коммита → повторная доставка.
-			//! }
-			continue
+		tracked, reset := tracker.track(msg)
+		if reset {
+			k.deps.Log.Warn("partition rewound after rebalance, offset state reset",
+				"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+		}
+		if !pool.submit(ctx, tracked) {
+			return
 		}
+	}
+}
 
-		if err := k.commitWithRetry(ctx, msg); err != nil {
-			k.deps.Log.Error("commit failed", "err", fmt.Errorf("%w: %w", ErrCommitMessage, err),
-				"topic", msg.Topic, "offset", msg.Offset)
-			// Не удалось зафиксировать — сообщение придет снова (at-least-once).
-			continue
+// process обрабатывает одно сообщение и сообщает, можно ли коммитить его
+// оффсет. Сообщение с ошибкой или паникой обработчика перекладывается в
+// топик повторов или DLQ; пока это не удалось, воркер повторяет попытки, не
+// пропуская сообщение, и держит остальную очередь партиции. false
+// возвращается только при отмене контекста, когда консюмер уже
+// останавливается и сообщение придёт снова.
+func (k *Kafka) process(ctx context.Context, msg kafka.Message, delayed bool) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if delayed && !waitNotBefore(ctx, msg) {
+		return false
+	}
+
+	err := k.dispatch(ctx, msg)
+	if err == nil {
+		return true
+	}
+	k.deps.Log.Error("handler failed", "err", err, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+
+	backoff := retry.NewBackoff(&k.deps.Cfg.CommitBackoff)
+	for {
+		target, routeErr := k.routeFailed(ctx, msg, err)
+		if routeErr == nil {
+			k.deps.Log.Warn("failed message rerouted", "topic", msg.Topic, "offset", msg.Offset, "target", target)
+			return true
+		}
+		k.deps.Log.Error("failed message routing failed", "err", routeErr,
+			"topic", msg.Topic, "offset", msg.Offset)
+
+		backoff.Sleep(ctx)
+		if ctx.Err() != nil {
+			// Оффсет не коммитится — сообщение придет снова.
+			return false
 		}
-		k.deps.Log.Debug("message committed", "topic", msg.Topic, "offset", msg.Offset)
 	}
 }
 
-func (k *Kafka) fetch(ctx context.Context) (kafka.Message, error) {
-	m, err := k.consumer.FetchMessage(ctx)
+// dispatch вызывает обработчик сообщения, превращая его панику в ошибку,
+// чтобы сообщение ушло в повтор, а не оставило партицию без коммита.
+func (k *Kafka) dispatch(ctx context.Context, msg kafka.Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrHandlerPanic, r)
+		}
+	}()
+	return k.router.Dispatch(ctx, msg)
+}
+
+func (k *Kafka) fetch(ctx context.Context, reader *kafka.Reader) (kafka.Message, error) {
+	m, err := reader.FetchMessage(ctx)
 	if err != nil {
 		return kafka.Message{}, fmt.Errorf("error fetch: %w", err)
 	}
@@ -282,11 +482,11 @@ func (k *Kafka) fetch(ctx context.Context) (kafka.Message, error) {
 	return m, nil
 }
 
-func (k *Kafka) commitWithRetry(ctx context.Context, m kafka.Message) error {
+func (k *Kafka) commitWithRetry(ctx context.Context, reader *kafka.Reader, msgs ...kafka.Message) error {
 	backoff := retry.NewBackoff(&k.deps.Cfg.CommitBackoff)
 	attempts := backoff.Attempts()
 	for i := 0; i < attempts; i++ {
-		if err := k.consumer.CommitMessages(ctx, m); err != nil {
+		if err := reader.CommitMessages(ctx, msgs...); err != nil {
 			if ctx.Err() != nil {
 				return ctx.Err()
 			}
@@ -298,10 +498,3 @@ func (k *Kafka) commitWithRetry(ctx context.Context, m kafka.Message) error {
 	}
 	return fmt.Errorf("commit retries exceeded: %w", ErrCommitMessage)
 }
-
-// func (k *Kafka) toDLQ(ctx context.Context, m kafka.Message, cause error) error {
-// 	if k.dlq == nil {
-// 		return nil
-// 	}
-// 	return k.dlq.Publish(ctx, m, cause)
-// }
@@ -1,16 +1,173 @@
 package kafka
 
 import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
 	"github.com/segmentio/kafka-go"
 )
 
-// Создает нового продюсера, записывает в передаваемый на входе топик.
-// Запуск происходит в инициализации кафки
-func createWriter(address, topic string) *kafka.Writer {
+// Балансировщики партиций, доступные в KafkaConfig.Balancer.
+const (
+	// BalancerHash выбирает партицию по FNV-1a хешу ключа (как sarama).
+	BalancerHash = "hash"
+	// BalancerMurmur2 совместим с партиционером Java-клиента Kafka.
+	BalancerMurmur2 = "murmur2"
+	// BalancerCRC32 совместим с партиционером librdkafka.
+	BalancerCRC32 = "crc32"
+	// BalancerRoundRobin распределяет сообщения по кругу, игнорируя ключ.
+	BalancerRoundRobin = "round-robin"
+	// BalancerLeastBytes выбирает наименее загруженную партицию, игнорируя ключ.
+	BalancerLeastBytes = "least-bytes"
+)
+
+// newBalancer возвращает балансировщик по имени из конфигурации. Пустое имя
+// означает hash. Ключевые балансировщики сохраняют порядок сообщений с
+// одинаковым ключом; сообщения без ключа они распределяют по кругу.
+func newBalancer(name string) (kafka.Balancer, error) {
+	switch name {
+	case "", BalancerHash:
+		return &kafka.Hash{}, nil
+	case BalancerMurmur2:
+		return kafka.Murmur2Balancer{}, nil
+	case BalancerCRC32:
+		return kafka.CRC32Balancer{}, nil
+	case BalancerRoundRobin:
+		return &kafka.RoundRobin{}, nil
+	case BalancerLeastBytes:
+		return &kafka.LeastBytes{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBalancer, name)
+	}
+}
+
+// Уровни подтверждения записи, доступные в ProducerConfig.RequiredAcks.
+const (
+	// AcksAll ждёт подтверждения от всех in-sync реплик.
+	AcksAll = "all"
+	// AcksOne ждёт подтверждения только от лидера партиции.
+	AcksOne = "one"
+	// AcksNone не ждёт подтверждения.
+	AcksNone = "none"
+)
+
+// Кодеки сжатия, доступные в ProducerConfig.Compression.
+const (
+	CompressionNone   = "none"
+	CompressionGzip   = "gzip"
+	CompressionSnappy = "snappy"
+	CompressionLz4    = "lz4"
+	CompressionZstd   = "zstd"
+)
+
+// writerOptions — разобранные настройки продюсера.
+type writerOptions struct {
+	balancer     kafka.Balancer
+	batchSize    int
+	batchTimeout time.Duration
+	requiredAcks kafka.RequiredAcks
+	compression  kafka.Compression
+	async        bool
+	autoCreate   bool
+}
+
+// producerOptions разбирает настройки продюсера из конфигурации.
+func producerOptions(cfg *config.KafkaConfig) (writerOptions, error) {
+	balancer, err := newBalancer(cfg.Balancer)
+	if err != nil {
+		return writerOptions{}, err
+	}
+	acks, err := newRequiredAcks(cfg.Producer.RequiredAcks)
+	if err != nil {
+		return writerOptions{}, err
+	}
+	compression, err := newCompression(cfg.Producer.Compression)
+	if err != nil {
+		return writerOptions{}, err
+	}
+	return writerOptions{
+		balancer:     balancer,
+		batchSize:    cfg.Producer.BatchSize,
+		batchTimeout: cfg.Producer.BatchTimeout,
+		requiredAcks: acks,
+		compression:  compression,
+		async:        cfg.Producer.Async,
+		autoCreate:   cfg.Producer.AutoCreateTopics,
+	}, nil
+}
+
+// newRequiredAcks возвращает уровень подтверждения по имени. Пустое имя
+// означает all.
+func newRequiredAcks(name string) (kafka.RequiredAcks, error) {
+	switch name {
+	case "", AcksAll:
+		return kafka.RequireAll, nil
+	case AcksOne:
+		return kafka.RequireOne, nil
+	case AcksNone:
+		return kafka.RequireNone, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownRequiredAcks, name)
+	}
+}
+
+// newCompression возвращает кодек сжатия по имени. Пустое имя означает
+// отсутствие сжатия.
+func newCompression(name string) (kafka.Compression, error) {
+	switch name {
+	case "", CompressionNone:
+		return 0, nil
+	case CompressionGzip:
+		return kafka.Gzip, nil
+	case CompressionSnappy:
+		return kafka.Snappy, nil
+	case CompressionLz4:
+		return kafka.Lz4, nil
+	case CompressionZstd:
+		return kafka.Zstd, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCompression, name)
+	}
+}
+
+// Создает нового продюсера без фиксированного топика: топик задаётся в
+// каждом сообщении, что позволяет писать и в топики повторов, и в DLQ.
+// Запуск происходит в инициализации кафки; transport задаёт TLS и SASL.
+// Нулевые размер пачки и таймаут оставляют значения kafka-go по умолчанию.
+func createWriter(address string, transport *kafka.Transport, opts writerOptions) *kafka.Writer {
 	w := &kafka.Writer{
-		Addr:     kafka.TCP(address),
-		Topic:    topic,
-		Balancer: &kafka.LeastBytes{},
+		Addr:                   kafka.TCP(address),
+		Balancer:               opts.balancer,
+		Transport:              transport,
+		BatchSize:              opts.batchSize,
+		BatchTimeout:           opts.batchTimeout,
+		RequiredAcks:           opts.requiredAcks,
+		Compression:            opts.compression,
+		Async:                  opts.async,
+		AllowAutoTopicCreation: opts.autoCreate,
 	}
 	return w
 }
+
+// complete вызывается продюсером в асинхронном режиме по итогам записи
+// пачки: логирует сбой и передаёт результат обработчику HandleCompletion.
+func (k *Kafka) complete(messages []kafka.Message, err error) {
+	if err != nil {
+		k.deps.Log.Error("kafka async write failed",
+			slog.String("error", fmt.Errorf("%w: %w", ErrWriteMessage, err).Error()),
+			slog.Int("messages", len(messages)),
+		)
+	}
+	if k.onCompletion == nil {
+		return
+	}
+
+	delivered := make([]events.Message, 0, len(messages))
+	for _, msg := range messages {
+		delivered = append(delivered, toEventMessage(msg))
+	}
+	k.onCompletion(delivered, err)
+}
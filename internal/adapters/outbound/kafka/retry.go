@@ -0,0 +1,200 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/pkg/retry"
+	"github.com/segmentio/kafka-go"
+)
+
+// Заголовки, которыми адаптер помечает сообщения в топиках повторов и DLQ.
+const (
+	// HeaderAttempt — число неудачных попыток обработки сообщения.
+	HeaderAttempt = "x-retry-attempt"
+	// HeaderNotBefore — момент (unix ms), раньше которого сообщение из топика
+	// повторов не обрабатывается.
+	HeaderNotBefore = "x-retry-not-before"
+	// HeaderError — текст ошибки последней попытки.
+	HeaderError = "x-error"
+	// HeaderSourceTopic — топик, в который сообщение было опубликовано изначально.
+	HeaderSourceTopic = "x-source-topic"
+	// HeaderSourcePartition — партиция исходного сообщения.
+	HeaderSourcePartition = "x-source-partition"
+	// HeaderSourceOffset — оффсет исходного сообщения.
+	HeaderSourceOffset = "x-source-offset"
+)
+
+const (
+	retryTopicInfix = ".retry."
+	dlqTopicSuffix  = ".dlq"
+)
+
+// RetryTopic возвращает имя топика повторов уровня attempt для топика topic,
+// например "messages.retry.2".
+func RetryTopic(topic string, attempt int) string {
+	return topic + retryTopicInfix + strconv.Itoa(attempt)
+}
+
+// DLQTopic возвращает имя топика недоставленных сообщений для topic.
+func DLQTopic(topic string) string {
+	return topic + dlqTopicSuffix
+}
+
+// retryPolicy возвращает политику повторов топика: переопределение из
+// TopicRetry либо общую Retry. Attempts равный нулю отправляет сообщение
+// в DLQ после первой же ошибки.
+func (k *Kafka) retryPolicy(topic string) config.RetryConfig {
+	if policy, ok := k.deps.Cfg.TopicRetry[topic]; ok {
+		return policy
+	}
+	return k.deps.Cfg.Retry
+}
+
+// routeFailed публикует сообщение, обработка которого завершилась ошибкой,
+// в следующий топик повторов с задержкой по политике исходного топика, а
+// после исчерпания попыток — в DLQ. Исходные заголовки сохраняются.
+// Счётчик попыток продолжается только для сообщений из топиков повторов.
+func (k *Kafka) routeFailed(ctx context.Context, msg kafka.Message, cause error) (string, error) {
+	source := sourceTopic(msg)
+	retried := source != msg.Topic
+	attempt := 1
+	if retried {
+		attempt = headerInt(msg.Headers, HeaderAttempt) + 1
+	}
+	policy := k.retryPolicy(source)
+
+	out := kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: failureHeaders(msg, cause, attempt, retried),
+	}
+
+	if attempt <= policy.Attempts {
+		out.Topic = RetryTopic(source, attempt)
+		notBefore := time.Now().Add(retry.Delay(&policy, attempt))
+		out.Headers = append(out.Headers, kafka.Header{
+			Key:   HeaderNotBefore,
+			Value: []byte(strconv.FormatInt(notBefore.UnixMilli(), 10)),
+		})
+	} else {
+		out.Topic = DLQTopic(source)
+	}
+
+	if err := k.reliable.WriteMessages(ctx, out); err != nil {
+		return out.Topic, fmt.Errorf("publish to %s: %w", out.Topic, err)
+	}
+	return out.Topic, nil
+}
+
+// retryTopics перечисляет топики повторов всех топиков с обработчиками.
+func (k *Kafka) retryTopics() []string {
+	var topics []string
+	for _, topic := range k.router.Topics() {
+		policy := k.retryPolicy(topic)
+		for attempt := 1; attempt <= policy.Attempts; attempt++ {
+			topics = append(topics, RetryTopic(topic, attempt))
+		}
+	}
+	return topics
+}
+
+// waitNotBefore приостанавливает обработку сообщения из топика повторов до
+// момента, указанного в HeaderNotBefore. Возвращает false при отмене ctx.
+func waitNotBefore(ctx context.Context, msg kafka.Message) bool {
+	notBefore := headerInt(msg.Headers, HeaderNotBefore)
+	if notBefore == 0 {
+		return true
+	}
+
+	delay := time.Until(time.UnixMilli(int64(notBefore)))
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// sourceTopic возвращает топик, обработчик которого отвечает за сообщение.
+// Заголовок HeaderSourceTopic учитывается, только если сообщение прочитано
+// из топика повторов этого же топика: в основные топики пишут сторонние
+// продюсеры, и заголовок не должен уводить их сообщения к чужому
+// обработчику.
+func sourceTopic(msg kafka.Message) string {
+	source := headerValue(msg.Headers, HeaderSourceTopic)
+	if source != "" && isRetryTopicOf(msg.Topic, source) {
+		return source
+	}
+	return msg.Topic
+}
+
+// isRetryTopicOf сообщает, является ли topic топиком повторов source вида
+// "<source>.retry.<n>".
+func isRetryTopicOf(topic, source string) bool {
+	level, ok := strings.CutPrefix(topic, source+retryTopicInfix)
+	if !ok {
+		return false
+	}
+	attempt, err := strconv.Atoi(level)
+	return err == nil && attempt > 0
+}
+
+// failureHeaders копирует пользовательские заголовки сообщения и добавляет
+// сведения об ошибке. Координаты исходного сообщения сохраняются с первой
+// попытки, чтобы DLQ указывал на оригинал, а не на топик повторов; у
+// сообщения из основного топика (retried равен false) они заменяются его
+// собственными.
+func failureHeaders(msg kafka.Message, cause error, attempt int, retried bool) []kafka.Header {
+	headers := make([]kafka.Header, 0, len(msg.Headers)+6)
+	for _, header := range msg.Headers {
+		if strings.HasPrefix(header.Key, "x-retry-") || header.Key == HeaderError {
+			continue
+		}
+		if !retried && strings.HasPrefix(header.Key, "x-source-") {
+			continue
+		}
+		headers = append(headers, header)
+	}
+
+	headers = append(headers,
+		kafka.Header{Key: HeaderAttempt, Value: []byte(strconv.Itoa(attempt))},
+		kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+	)
+
+	if !retried {
+		headers = append(headers,
+			kafka.Header{Key: HeaderSourceTopic, Value: []byte(msg.Topic)},
+			kafka.Header{Key: HeaderSourcePartition, Value: []byte(strconv.Itoa(msg.Partition))},
+			kafka.Header{Key: HeaderSourceOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		)
+	}
+	return headers
+}
+
+func headerValue(headers []kafka.Header, key string) string {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+func headerInt(headers []kafka.Header, key string) int {
+	value, err := strconv.Atoi(headerValue(headers, key))
+	if err != nil {
+		return 0
+	}
+	return value
+}
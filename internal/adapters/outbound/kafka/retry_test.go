@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestSourceTopic(t *testing.T) {
+	tests := []struct {
+		name   string
+		topic  string
+		source string
+		want   string
+	}{
+		{name: "main topic without header", topic: "chat.messages", want: "chat.messages"},
+		{name: "retry topic of the source", topic: "chat.messages.retry.2", source: "chat.messages", want: "chat.messages"},
+		{name: "main topic ignores the header", topic: "chat.messages", source: "chat.receipts", want: "chat.messages"},
+		{name: "retry topic of another source", topic: "chat.messages.retry.1", source: "chat.receipts", want: "chat.messages.retry.1"},
+		{name: "dlq topic ignores the header", topic: "chat.messages.dlq", source: "chat.messages", want: "chat.messages.dlq"},
+		{name: "malformed retry level", topic: "chat.messages.retry.x", source: "chat.messages", want: "chat.messages.retry.x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := kafka.Message{Topic: tt.topic}
+			if tt.source != "" {
+				msg.Headers = []kafka.Header{{Key: HeaderSourceTopic, Value: []byte(tt.source)}}
+			}
+			if got := sourceTopic(msg); got != tt.want {
+				t.Fatalf("sourceTopic = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailureHeadersReplaceForgedSource(t *testing.T) {
+	msg := kafka.Message{
+		Topic:     "chat.messages",
+		Partition: 1,
+		Offset:    10,
+		Headers: []kafka.Header{
+			{Key: "traceparent", Value: []byte("tp")},
+			{Key: HeaderSourceTopic, Value: []byte("chat.receipts")},
+			{Key: HeaderSourceOffset, Value: []byte("99")},
+			{Key: HeaderAttempt, Value: []byte("5")},
+		},
+	}
+
+	headers := failureHeaders(msg, errors.New("boom"), 1, false)
+
+	want := map[string]string{
+		"traceparent":         "tp",
+		HeaderAttempt:         "1",
+		HeaderError:           "boom",
+		HeaderSourceTopic:     "chat.messages",
+		HeaderSourcePartition: "1",
+		HeaderSourceOffset:    "10",
+	}
+	if len(headers) != len(want) {
+		t.Fatalf("headers = %v, want %v", headers, want)
+	}
+	for key, value := range want {
+		if got := headerValue(headers, key); got != value {
+			t.Fatalf("header %s = %q, want %q", key, got, value)
+		}
+	}
+}
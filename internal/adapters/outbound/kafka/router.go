@@ -24,12 +24,26 @@ func (r *Router) Handle(topic string, h Handler) {
 	r.handlers[topic] = h
 }
 
+// Topics возвращает топики, для которых зарегистрированы обработчики.
+func (r *Router) Topics() []string {
+	topics := make([]string, 0, len(r.handlers))
+	for topic := range r.handlers {
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
 // Dispatch преобразует kafka.Message в events.Message и передаёт его обработчику.
+// Сообщения из топиков повторов обрабатываются обработчиком исходного топика.
+// Контекст трассировки из заголовка traceparent передаётся обработчику через
+// ctx (см. events.TraceParent).
 func (r *Router) Dispatch(ctx context.Context, msg kafka.Message) error {
-	h, ok := r.handlers[msg.Topic]
+	topic := sourceTopic(msg)
+	h, ok := r.handlers[topic]
 	if !ok {
-		return fmt.Errorf("no handler for topic %s", msg.Topic)
+		return fmt.Errorf("no handler for topic %s", topic)
 	}
+	ctx = events.WithTraceParent(ctx, headerValue(msg.Headers, events.HeaderTraceParent))
 	return h(ctx, toEventMessage(msg))
 }
 
@@ -54,3 +68,16 @@ func headersToMap(headers []kafka.Header) map[string][]byte {
 
 	return result
 }
+
+func mapToHeaders(headers map[string][]byte) []kafka.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	result := make([]kafka.Header, 0, len(headers))
+	for key, value := range headers {
+		result = append(result, kafka.Header{Key: key, Value: value})
+	}
+
+	return result
+}
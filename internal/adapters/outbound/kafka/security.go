@@ -0,0 +1,138 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Механизмы SASL, доступные в KafkaConfig.SASL.Mechanism.
+const (
+	// SASLPlain передаёт логин и пароль открытым текстом; используйте с TLS.
+	SASLPlain = "plain"
+	// SASLScramSHA256 — SCRAM с хешем SHA-256.
+	SASLScramSHA256 = "scram-sha-256"
+	// SASLScramSHA512 — SCRAM с хешем SHA-512.
+	SASLScramSHA512 = "scram-sha-512"
+)
+
+const dialTimeout = 10 * time.Second
+
+// newDialer возвращает dialer с настройками TLS и SASL из конфигурации.
+// Он используется для проверки соединения, чтения и служебных запросов.
+func newDialer(cfg *config.KafkaConfig) (*kafka.Dialer, error) {
+	tlsCfg, mechanism, err := securitySettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Dialer{
+		Timeout:       dialTimeout,
+		DualStack:     true,
+		TLS:           tlsCfg,
+		SASLMechanism: mechanism,
+	}, nil
+}
+
+// newTransport возвращает транспорт продюсера с теми же настройками TLS и
+// SASL, что и у dialer.
+func newTransport(cfg *config.KafkaConfig) (*kafka.Transport, error) {
+	tlsCfg, mechanism, err := securitySettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Transport{
+		DialTimeout: dialTimeout,
+		TLS:         tlsCfg,
+		SASL:        mechanism,
+	}, nil
+}
+
+func securitySettings(cfg *config.KafkaConfig) (*tls.Config, sasl.Mechanism, error) {
+	tlsCfg, err := newTLSConfig(&cfg.TLS)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: tls: %w", ErrSecurityConfig, err)
+	}
+	mechanism, err := newSASLMechanism(&cfg.SASL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: sasl: %w", ErrSecurityConfig, err)
+	}
+	return tlsCfg, mechanism, nil
+}
+
+// newTLSConfig собирает tls.Config. Возвращает nil, если TLS выключен.
+func newTLSConfig(cfg *config.KafkaTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify, //nolint:gosec // только для локальной разработки
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in ca file %s", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("cert-file and key-file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// newSASLMechanism возвращает механизм аутентификации. Пустое имя
+// означает подключение без SASL.
+func newSASLMechanism(cfg *config.KafkaSASLConfig) (sasl.Mechanism, error) {
+	name := strings.ToLower(cfg.Mechanism)
+	if name == "" {
+		return nil, nil
+	}
+	if cfg.Username == "" {
+		return nil, fmt.Errorf("username is required for %s", name)
+	}
+
+	switch name {
+	case SASLPlain:
+		return plain.Mechanism{Username: cfg.Username, Password: cfg.Password}, nil
+	case SASLScramSHA256:
+		mechanism, err := scram.Mechanism(scram.SHA256, cfg.Username, cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("scram-sha-256: %w", err)
+		}
+		return mechanism, nil
+	case SASLScramSHA512:
+		mechanism, err := scram.Mechanism(scram.SHA512, cfg.Username, cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("scram-sha-512: %w", err)
+		}
+		return mechanism, nil
+	default:
+		return nil, fmt.Errorf("unknown mechanism %q", cfg.Mechanism)
+	}
+}
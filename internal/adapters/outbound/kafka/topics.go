@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+// Topic возвращает имя топика Kafka для логического имени name из
+// config.KafkaConfig.Topics. Пустое имя означает events.TopicMessages.
+// Реальные топики из конфигурации, их топики повторов и DLQ возвращаются
+// без изменений. Для остальных имён возвращается ErrUnknownTopic: опечатка
+// не должна превращаться в запись в новый топик.
+//
+// Пример:
+//
+//	kfk.Topic(events.TopicReceipts) // "chat.receipts", nil
+//	kfk.Topic("chat.messages.dlq")  // "chat.messages.dlq", nil
+func (k *Kafka) Topic(name string) (string, error) {
+	if name == "" {
+		name = events.TopicMessages
+	}
+	if topic, ok := k.logicalTopic(name); ok {
+		return topic, nil
+	}
+	if k.knownTopic(name) {
+		return name, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownTopic, name)
+}
+
+// logicalTopic сопоставляет логическое имя с топиком Kafka. Для
+// events.TopicMessages учитывается устаревший параметр test-topic, если
+// топик не задан в Topics.
+func (k *Kafka) logicalTopic(name string) (string, bool) {
+	if topic := k.deps.Cfg.Topics[name]; topic != "" {
+		return topic, true
+	}
+	if name == events.TopicMessages && k.deps.Cfg.TestTopic != "" {
+		return k.deps.Cfg.TestTopic, true
+	}
+	return "", false
+}
+
+// knownTopic сообщает, является ли name настроенным топиком Kafka, его
+// топиком повторов или DLQ.
+func (k *Kafka) knownTopic(name string) bool {
+	configured := make([]string, 0, len(k.deps.Cfg.Topics)+1)
+	for _, topic := range k.deps.Cfg.Topics {
+		configured = append(configured, topic)
+	}
+	if k.deps.Cfg.TestTopic != "" {
+		configured = append(configured, k.deps.Cfg.TestTopic)
+	}
+
+	for _, topic := range configured {
+		if topic == "" {
+			continue
+		}
+		if name == topic || name == DLQTopic(topic) || strings.HasPrefix(name, topic+retryTopicInfix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,345 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	defaultWorkerQueueSize = 64
+	defaultCommitInterval  = time.Second
+	defaultCommitBatch     = 100
+
+	// finalCommitTimeout ограничивает фиксацию оффсетов при остановке, когда
+	// контекст чтения уже отменён.
+	finalCommitTimeout = 5 * time.Second
+
+	// workerIdleTimeout — время без сообщений, после которого воркер
+	// останавливается. Так освобождаются воркеры партиций, отданных другому
+	// участнику группы при ребалансировке.
+	workerIdleTimeout = time.Minute
+)
+
+// partitionKey идентифицирует партицию топика.
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// workerKey идентифицирует воркер: партицию и слот внутри неё.
+type workerKey struct {
+	partitionKey
+	slot int
+}
+
+// trackedMessage — прочитанное сообщение вместе с поколением партиции, в
+// котором его выдал offsetTracker.
+type trackedMessage struct {
+	kafka.Message
+	generation uint64
+}
+
+// consumerSettings возвращает параметры конкурентного чтения с безопасными
+// значениями по умолчанию.
+func consumerSettings(cfg *config.ConsumerConfig) config.ConsumerConfig {
+	settings := *cfg
+	if settings.WorkersPerPartition <= 0 {
+		settings.WorkersPerPartition = 1
+	}
+	if settings.QueueSize <= 0 {
+		settings.QueueSize = defaultWorkerQueueSize
+	}
+	if settings.CommitInterval <= 0 {
+		settings.CommitInterval = defaultCommitInterval
+	}
+	if settings.CommitBatch <= 0 {
+		settings.CommitBatch = defaultCommitBatch
+	}
+	return settings
+}
+
+// workerPool раздаёт сообщения воркерам. Сообщения одной партиции попадают в
+// один воркер, поэтому их порядок сохраняется. Если на партицию выделено
+// несколько воркеров, сообщение выбирает слот по хешу ключа: порядок
+// сохраняется для каждого ключа (диалога), но не для партиции целиком.
+//
+// Свободный воркер с пустой очередью, не получавший сообщений дольше idle,
+// останавливается; когда у партиции не остаётся воркеров, вызывается
+// stopped. Все методы, кроме process, вызываются из горутины чтения.
+type workerPool struct {
+	slots   int
+	size    int
+	idle    time.Duration
+	process func(trackedMessage)
+	stopped func(partitionKey)
+
+	workers   map[workerKey]*worker
+	lastSweep time.Time
+	wg        sync.WaitGroup
+}
+
+// worker — очередь воркера и признак того, что он обрабатывает сообщение.
+type worker struct {
+	queue    chan trackedMessage
+	busy     atomic.Bool
+	lastUsed time.Time
+}
+
+// idleSince сообщает, простаивает ли воркер с момента since.
+func (w *worker) idleSince(since time.Time) bool {
+	return len(w.queue) == 0 && !w.busy.Load() && w.lastUsed.Before(since)
+}
+
+func newWorkerPool(cfg config.ConsumerConfig, process func(trackedMessage), stopped func(partitionKey)) *workerPool {
+	return &workerPool{
+		slots:     cfg.WorkersPerPartition,
+		size:      cfg.QueueSize,
+		idle:      workerIdleTimeout,
+		process:   process,
+		stopped:   stopped,
+		workers:   make(map[workerKey]*worker),
+		lastSweep: time.Now(),
+	}
+}
+
+// submit передаёт сообщение воркеру, при необходимости запуская его.
+// Блокируется, пока очередь воркера заполнена, что ограничивает чтение
+// скоростью обработки. Возвращает false при отмене контекста.
+func (p *workerPool) submit(ctx context.Context, msg trackedMessage) bool {
+	now := time.Now()
+	p.sweep(now)
+
+	key := workerKey{
+		partitionKey: partitionKey{topic: msg.Topic, partition: msg.Partition},
+		slot:         p.slot(msg.Message),
+	}
+
+	w, ok := p.workers[key]
+	if !ok {
+		w = &worker{queue: make(chan trackedMessage, p.size)}
+		p.workers[key] = w
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for msg := range w.queue {
+				w.busy.Store(true)
+				p.process(msg)
+				w.busy.Store(false)
+			}
+		}()
+	}
+	w.lastUsed = now
+
+	select {
+	case w.queue <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (p *workerPool) slot(msg kafka.Message) int {
+	if p.slots == 1 {
+		return 0
+	}
+	if len(msg.Key) == 0 {
+		return int(msg.Offset % int64(p.slots))
+	}
+	h := fnv.New32a()
+	_, _ = h.Write(msg.Key)
+	return int(h.Sum32() % uint32(p.slots))
+}
+
+// sweep останавливает простаивающие воркеры не чаще раза в idle.
+func (p *workerPool) sweep(now time.Time) {
+	if now.Sub(p.lastSweep) < p.idle {
+		return
+	}
+	p.lastSweep = now
+
+	stopped := make(map[partitionKey]struct{})
+	for key, w := range p.workers {
+		if !w.idleSince(now.Add(-p.idle)) {
+			continue
+		}
+		close(w.queue)
+		delete(p.workers, key)
+		stopped[key.partitionKey] = struct{}{}
+	}
+	for key := range p.workers {
+		delete(stopped, key.partitionKey)
+	}
+	for partition := range stopped {
+		p.stopped(partition)
+	}
+}
+
+// close останавливает воркеры и ждёт, пока они разберут свои очереди.
+func (p *workerPool) close() {
+	for _, w := range p.workers {
+		close(w.queue)
+	}
+	p.wg.Wait()
+}
+
+// partitionOffsets отслеживает выданные воркерам сообщения одной партиции в
+// порядке чтения. last — последний прочитанный оффсет, generation — номер
+// поколения, в котором партиция читается.
+type partitionOffsets struct {
+	inflight   []int64
+	done       map[int64]struct{}
+	ready      *kafka.Message
+	last       int64
+	generation uint64
+}
+
+// offsetTracker определяет, до какого оффсета можно коммитить: только до
+// последнего сообщения, перед которым обработаны все прочитанные ранее.
+// Так сообщение, обработка которого ещё идёт или прервалась, не будет
+// пропущено после перезапуска.
+//
+// После ребалансировки группы читатель снова читает партицию с
+// зафиксированного оффсета. Оффсет, не превышающий уже прочитанный,
+// означает новое поколение группы: состояние партиции сбрасывается, а
+// завершения сообщений прошлого поколения игнорируются, даже если их
+// оффсеты совпадают с перечитанными.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+	generation uint64
+	pending    int
+	batch      int
+	flush      chan struct{}
+}
+
+func newOffsetTracker(batch int) *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[partitionKey]*partitionOffsets),
+		batch:      batch,
+		flush:      make(chan struct{}, 1),
+	}
+}
+
+// track регистрирует прочитанное сообщение. Вызывается до передачи
+// сообщения воркеру. Второй результат равен true, если партиция была
+// перечитана после ребалансировки и её состояние сброшено.
+func (t *offsetTracker) track(msg kafka.Message) (trackedMessage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+	state, ok := t.partitions[key]
+	reset := ok && msg.Offset <= state.last
+	if !ok || reset {
+		t.generation++
+		state = &partitionOffsets{done: make(map[int64]struct{}), generation: t.generation}
+		t.partitions[key] = state
+	}
+	state.inflight = append(state.inflight, msg.Offset)
+	state.last = msg.Offset
+	return trackedMessage{Message: msg, generation: state.generation}, reset
+}
+
+// complete отмечает сообщение обработанным и сдвигает границу коммита
+// партиции. Когда готовых к коммиту сообщений набирается CommitBatch,
+// сигнализирует о внеочередной фиксации.
+func (t *offsetTracker) complete(msg trackedMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.partitions[partitionKey{topic: msg.Topic, partition: msg.Partition}]
+	if state == nil || state.generation != msg.generation {
+		// Сообщение прошлого поколения группы.
+		return
+	}
+	if _, ok := slices.BinarySearch(state.inflight, msg.Offset); !ok {
+		return
+	}
+	state.done[msg.Offset] = struct{}{}
+
+	for len(state.inflight) > 0 {
+		head := state.inflight[0]
+		if _, ok := state.done[head]; !ok {
+			break
+		}
+		delete(state.done, head)
+		state.inflight = state.inflight[1:]
+		state.ready = &kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: head}
+		t.pending++
+	}
+
+	if t.pending >= t.batch {
+		select {
+		case t.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// forget удаляет состояние партиции, воркеры которой остановлены, если в
+// нём не осталось необработанных и незафиксированных сообщений.
+func (t *offsetTracker) forget(key partitionKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.partitions[key]
+	if state != nil && len(state.inflight) == 0 && state.ready == nil {
+		delete(t.partitions, key)
+	}
+}
+
+// committable забирает готовые к коммиту оффсеты по всем партициям.
+func (t *offsetTracker) committable() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var msgs []kafka.Message
+	for _, state := range t.partitions {
+		if state.ready != nil {
+			msgs = append(msgs, *state.ready)
+			state.ready = nil
+		}
+	}
+	t.pending = 0
+	return msgs
+}
+
+// commitLoop периодически фиксирует оффсеты, готовые к коммиту: раз в
+// CommitInterval или раньше, если набралось CommitBatch сообщений.
+func (k *Kafka) commitLoop(ctx context.Context, reader *kafka.Reader, tracker *offsetTracker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-tracker.flush:
+		}
+		k.commit(ctx, reader, tracker)
+	}
+}
+
+// commit фиксирует накопленные оффсеты одним запросом.
+func (k *Kafka) commit(ctx context.Context, reader *kafka.Reader, tracker *offsetTracker) {
+	msgs := tracker.committable()
+	if len(msgs) == 0 {
+		return
+	}
+	if err := k.commitWithRetry(ctx, reader, msgs...); err != nil {
+		// Не удалось зафиксировать — сообщения придут снова (at-least-once).
+		k.deps.Log.Error("commit failed", "err", err, "partitions", len(msgs))
+		return
+	}
+	for _, msg := range msgs {
+		k.deps.Log.Debug("offset committed", "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
+	}
+}
@@ -0,0 +1,220 @@
+package kafka
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/segmentio/kafka-go"
+)
+
+const testTopic = "messages"
+
+func testMessage(partition int, offset int64) kafka.Message {
+	return kafka.Message{Topic: testTopic, Partition: partition, Offset: offset}
+}
+
+// trackerStep — шаг сценария: чтение или завершение сообщения. Завершение
+// ссылается на сообщение по порядковому номеру чтения, поэтому сценарий
+// может завершить сообщение прошлого поколения.
+type trackerStep struct {
+	read     *kafka.Message
+	complete int
+}
+
+func read(partition int, offset int64) trackerStep {
+	msg := testMessage(partition, offset)
+	return trackerStep{read: &msg}
+}
+
+func complete(n int) trackerStep {
+	return trackerStep{complete: n}
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     []trackerStep
+		want      []kafka.Message
+		wantReset []bool
+	}{
+		{
+			name:  "in order",
+			steps: []trackerStep{read(0, 1), read(0, 2), complete(0), complete(1)},
+			want:  []kafka.Message{testMessage(0, 2)},
+		},
+		{
+			name:  "out of order waits for the head",
+			steps: []trackerStep{read(0, 1), read(0, 2), read(0, 3), complete(1), complete(2)},
+		},
+		{
+			name:  "out of order releases the watermark",
+			steps: []trackerStep{read(0, 1), read(0, 2), read(0, 3), complete(2), complete(0)},
+			want:  []kafka.Message{testMessage(0, 1)},
+		},
+		{
+			name:  "gap holds later completions",
+			steps: []trackerStep{read(0, 1), read(0, 2), read(0, 3), complete(2), complete(1), complete(0)},
+			want:  []kafka.Message{testMessage(0, 3)},
+		},
+		{
+			name:  "partitions are independent",
+			steps: []trackerStep{read(0, 1), read(1, 7), read(0, 2), complete(1), complete(2)},
+			want:  []kafka.Message{testMessage(1, 7)},
+		},
+		{
+			name: "reset ignores completions of the previous generation",
+			steps: []trackerStep{
+				read(0, 10), read(0, 11), read(0, 12),
+				read(0, 10), read(0, 11),
+				complete(0), complete(1), complete(2),
+			},
+			wantReset: []bool{false, false, false, true, false},
+		},
+		{
+			name: "reset commits completions of the new generation",
+			steps: []trackerStep{
+				read(0, 10), read(0, 11),
+				read(0, 11), read(0, 12),
+				complete(0), complete(2), complete(1), complete(3),
+			},
+			want:      []kafka.Message{testMessage(0, 12)},
+			wantReset: []bool{false, false, true, false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newOffsetTracker(100)
+
+			var (
+				tracked []trackedMessage
+				resets  []bool
+			)
+			for _, step := range tt.steps {
+				if step.read != nil {
+					msg, reset := tracker.track(*step.read)
+					tracked = append(tracked, msg)
+					resets = append(resets, reset)
+					continue
+				}
+				tracker.complete(tracked[step.complete])
+			}
+
+			if tt.wantReset != nil && !slices.Equal(resets, tt.wantReset) {
+				t.Fatalf("track() resets = %v, want %v", resets, tt.wantReset)
+			}
+			got := tracker.committable()
+			slices.SortFunc(got, func(a, b kafka.Message) int { return a.Partition - b.Partition })
+			if !slices.EqualFunc(got, tt.want, sameOffset) {
+				t.Fatalf("committable() = %v, want %v", offsets(got), offsets(tt.want))
+			}
+			if again := tracker.committable(); len(again) != 0 {
+				t.Fatalf("repeated committable() = %v, want none", offsets(again))
+			}
+		})
+	}
+}
+
+func TestOffsetTrackerFlush(t *testing.T) {
+	tracker := newOffsetTracker(2)
+
+	first, _ := tracker.track(testMessage(0, 1))
+	second, _ := tracker.track(testMessage(0, 2))
+	tracker.complete(first)
+	select {
+	case <-tracker.flush:
+		t.Fatal("flush signalled before the batch is full")
+	default:
+	}
+
+	tracker.complete(second)
+	select {
+	case <-tracker.flush:
+	default:
+		t.Fatal("flush not signalled for a full batch")
+	}
+}
+
+func TestOffsetTrackerForget(t *testing.T) {
+	tracker := newOffsetTracker(100)
+	key := partitionKey{topic: testTopic, partition: 0}
+
+	msg, _ := tracker.track(testMessage(0, 5))
+	tracker.forget(key)
+	if tracker.partitions[key] == nil {
+		t.Fatal("forget() dropped a partition with messages in flight")
+	}
+
+	tracker.complete(msg)
+	tracker.forget(key)
+	if tracker.partitions[key] == nil {
+		t.Fatal("forget() dropped a partition with an uncommitted offset")
+	}
+
+	tracker.committable()
+	tracker.forget(key)
+	if tracker.partitions[key] != nil {
+		t.Fatal("forget() kept a drained partition")
+	}
+}
+
+func TestWorkerPoolStopsIdleWorkers(t *testing.T) {
+	processed := make(chan trackedMessage, 2)
+	stopped := make(chan partitionKey, 2)
+	pool := newWorkerPool(
+		config.ConsumerConfig{WorkersPerPartition: 1, QueueSize: 1},
+		func(msg trackedMessage) { processed <- msg },
+		func(key partitionKey) { stopped <- key },
+	)
+	defer pool.close()
+
+	ctx := context.Background()
+	pool.submit(ctx, trackedMessage{Message: testMessage(0, 1)})
+	pool.submit(ctx, trackedMessage{Message: testMessage(1, 1)})
+	<-processed
+	<-processed
+
+	waitIdle(t, pool)
+	pool.workers[workerKey{partitionKey: partitionKey{topic: testTopic, partition: 1}}].lastUsed = time.Now().Add(pool.idle)
+	pool.sweep(time.Now().Add(pool.idle))
+
+	select {
+	case key := <-stopped:
+		if key.partition != 0 {
+			t.Fatalf("stopped partition = %d, want 0", key.partition)
+		}
+	default:
+		t.Fatal("idle partition worker was not stopped")
+	}
+	if len(pool.workers) != 1 {
+		t.Fatalf("workers = %d, want 1", len(pool.workers))
+	}
+}
+
+func waitIdle(t *testing.T, pool *workerPool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for _, w := range pool.workers {
+		for w.busy.Load() {
+			if time.Now().After(deadline) {
+				t.Fatal("worker is still busy")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func sameOffset(a, b kafka.Message) bool {
+	return a.Topic == b.Topic && a.Partition == b.Partition && a.Offset == b.Offset
+}
+
+func offsets(msgs []kafka.Message) []int64 {
+	out := make([]int64, len(msgs))
+	for i, msg := range msgs {
+		out[i] = msg.Offset
+	}
+	return out
+}
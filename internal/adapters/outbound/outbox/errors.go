@@ -0,0 +1,12 @@
+package outbox
+
+import "errors"
+
+var (
+	// ErrClosed возвращается при обращении к незапущенной или остановленной очереди.
+	ErrClosed = errors.New("outbox: closed")
+	// ErrFull возвращается, когда файл очереди достиг OutboxConfig.MaxBytes.
+	ErrFull = errors.New("outbox: full")
+	// ErrCorruptRecord сообщает о повреждённой записи или курсоре очереди.
+	ErrCorruptRecord = errors.New("outbox: corrupt record")
+)
@@ -0,0 +1,434 @@
+// Package outbox реализует локальную очередь событий, которые не удалось
+// опубликовать в Kafka. Записи хранятся в append-only файле в формате
+// JSON Lines, позиция первой неопубликованной записи — в соседнем файле
+// курсора. Relay публикует записи строго в порядке добавления, поэтому
+// порядок событий с одинаковым ключом сохраняется.
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+const (
+	cursorSuffix   = ".cursor"
+	rejectedSuffix = ".rejected"
+)
+
+// Outbox — файловая очередь неопубликованных событий. Реализует компонент
+// приложения: Start открывает файл и восстанавливает очередь после
+// перезапуска, Stop закрывает его. Методы потокобезопасны.
+type Outbox struct {
+	name string
+	deps *OutboxDeps
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	cursor  int64
+	count   int
+	pending map[string]int
+	notify  chan struct{}
+}
+
+// OutboxDeps содержит зависимости очереди: конфигурацию и логгер.
+//
+//nolint:revive // имя согласовано с остальными адаптерами
+type OutboxDeps struct {
+	Cfg *config.OutboxConfig
+	Log *slog.Logger
+}
+
+// Entry — запись очереди, полученная через Peek. Next — позиция следующей
+// записи, её передают в Ack.
+type Entry struct {
+	Message events.Message
+	Next    int64
+}
+
+// record — формат строки файла очереди.
+type record struct {
+	Topic   string            `json:"topic,omitempty"`
+	Key     []byte            `json:"key,omitempty"`
+	Value   []byte            `json:"value"`
+	Headers map[string][]byte `json:"headers,omitempty"`
+}
+
+// NewOutbox валидирует зависимости и возвращает очередь. Файл открывается
+// в Start.
+func NewOutbox(deps *OutboxDeps) *Outbox {
+	if deps == nil || deps.Cfg == nil {
+		panic("outbox config cannot be nil")
+	}
+	if deps.Log == nil {
+		panic("logger cannot be nil")
+	}
+	if deps.Cfg.Path == "" {
+		panic("outbox path cannot be empty")
+	}
+
+	return &Outbox{
+		name:    "outbox",
+		deps:    deps,
+		pending: make(map[string]int),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// Name возвращает идентификатор компонента.
+func (o *Outbox) Name() string { return o.name }
+
+// Start открывает файл очереди, отбрасывает недописанную последнюю строку,
+// оставшуюся после аварийной остановки, и восстанавливает число ожидающих
+// записей по ключам.
+func (o *Outbox) Start(_ context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file != nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(o.deps.Cfg.Path), 0o750); err != nil {
+		return fmt.Errorf("create outbox dir: %w", err)
+	}
+	file, err := os.OpenFile(o.deps.Cfg.Path, os.O_RDWR|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("open outbox: %w", err)
+	}
+
+	cursor, err := readCursor(o.cursorPath())
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	o.file = file
+	if err := o.recover(cursor); err != nil {
+		_ = file.Close()
+		o.file = nil
+		return err
+	}
+
+	o.deps.Log.Debug("Outbox opened",
+		slog.String("path", o.deps.Cfg.Path),
+		slog.Int("pending", o.count),
+	)
+	if o.count > 0 {
+		o.signal()
+	}
+	return nil
+}
+
+// Stop закрывает файл очереди. Неопубликованные записи остаются в файле и
+// будут отправлены после перезапуска.
+func (o *Outbox) Stop(_ context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	if err != nil {
+		return fmt.Errorf("close outbox: %w", err)
+	}
+	o.deps.Log.Debug("Outbox closed", slog.Int("pending", o.count))
+	return nil
+}
+
+// Append дописывает событие в конец очереди и сбрасывает файл на диск.
+// После возврата без ошибки событие переживёт перезапуск процесса. Если
+// запись превысила бы OutboxConfig.MaxBytes, возвращается ErrFull.
+func (o *Outbox) Append(_ context.Context, msg events.Message) error {
+	line, err := json.Marshal(record{
+		Topic:   msg.Topic,
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: msg.Headers,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal outbox record: %w", err)
+	}
+	line = append(line, '\n')
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return ErrClosed
+	}
+	if limit := o.deps.Cfg.MaxBytes; limit > 0 && o.size+int64(len(line)) > limit {
+		return fmt.Errorf("%w: %d of %d bytes", ErrFull, o.size, limit)
+	}
+	if _, err := o.file.WriteAt(line, o.size); err != nil {
+		return fmt.Errorf("write outbox record: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("sync outbox: %w", err)
+	}
+
+	o.size += int64(len(line))
+	o.count++
+	o.pending[string(msg.Key)]++
+	o.signal()
+	return nil
+}
+
+// Pending сообщает, есть ли в очереди неопубликованные события с ключом
+// key. Пока они есть, новые события с тем же ключом нужно добавлять в
+// очередь, а не публиковать напрямую, иначе они обгонят ожидающие.
+func (o *Outbox) Pending(key []byte) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.pending[string(key)] > 0
+}
+
+// Len возвращает число неопубликованных событий.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.count
+}
+
+// Notify возвращает канал, в который приходит сигнал о новых записях.
+func (o *Outbox) Notify() <-chan struct{} { return o.notify }
+
+// Peek возвращает первую неопубликованную запись. ok равен false, если
+// очередь пуста.
+func (o *Outbox) Peek() (Entry, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return Entry{}, false, ErrClosed
+	}
+	if o.cursor >= o.size {
+		return Entry{}, false, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(o.file, o.cursor, o.size-o.cursor))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return Entry{}, false, fmt.Errorf("read outbox record at %d: %w", o.cursor, err)
+	}
+
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return Entry{}, false, fmt.Errorf("%w at %d: %w", ErrCorruptRecord, o.cursor, err)
+	}
+
+	return Entry{
+		Message: events.Message{Topic: rec.Topic, Key: rec.Key, Value: rec.Value, Headers: rec.Headers},
+		Next:    o.cursor + int64(len(line)),
+	}, true, nil
+}
+
+// Ack отмечает запись, полученную через Peek, опубликованной и сохраняет
+// курсор. Когда очередь опустевает, файл усекается.
+func (o *Outbox) Ack(entry Entry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return ErrClosed
+	}
+	if entry.Next <= o.cursor || entry.Next > o.size {
+		return fmt.Errorf("%w: ack position %d", ErrCorruptRecord, entry.Next)
+	}
+
+	o.release(string(entry.Message.Key))
+	o.count--
+	o.cursor = entry.Next
+
+	if o.cursor == o.size {
+		return o.reset()
+	}
+	return writeCursor(o.cursorPath(), o.cursor)
+}
+
+// Skip пропускает повреждённую запись в позиции курсора, чтобы она не
+// блокировала очередь. Возвращает пропущенную строку для журнала.
+func (o *Outbox) Skip() (string, error) {
+	return o.skip(false)
+}
+
+// Reject переносит запись в позиции курсора в файл "<path>.rejected" и
+// пропускает её. Так из очереди уходят события, которые брокер никогда не
+// примет; из файла их можно разобрать и опубликовать вручную. Если
+// перенести запись не удалось, она остаётся в очереди.
+func (o *Outbox) Reject() (string, error) {
+	return o.skip(true)
+}
+
+func (o *Outbox) skip(reject bool) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.file == nil {
+		return "", ErrClosed
+	}
+	if o.cursor >= o.size {
+		return "", nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(o.file, o.cursor, o.size-o.cursor))
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		return "", fmt.Errorf("read outbox record at %d: %w", o.cursor, err)
+	}
+	if reject {
+		if err := appendRejected(o.deps.Cfg.Path+rejectedSuffix, line); err != nil {
+			return "", err
+		}
+	}
+
+	var rec record
+	if err := json.Unmarshal(line, &rec); err == nil {
+		o.release(string(rec.Key))
+	}
+	o.count--
+	o.cursor += int64(len(line))
+	if o.cursor == o.size {
+		return string(line), o.reset()
+	}
+	return string(line), writeCursor(o.cursorPath(), o.cursor)
+}
+
+// recover восстанавливает состояние очереди из файла, начиная с cursor.
+func (o *Outbox) recover(cursor int64) error {
+	info, err := o.file.Stat()
+	if err != nil {
+		return fmt.Errorf("stat outbox: %w", err)
+	}
+	size := info.Size()
+	if cursor > size {
+		cursor = size
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(o.file, cursor, size-cursor))
+	offset := cursor
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				o.deps.Log.Warn("Outbox truncated incomplete record",
+					slog.Int64("offset", offset),
+					slog.Int("bytes", len(line)),
+				)
+				if err := o.file.Truncate(offset); err != nil {
+					return fmt.Errorf("truncate outbox: %w", err)
+				}
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("scan outbox: %w", err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err == nil {
+			o.pending[string(rec.Key)]++
+		}
+		o.count++
+		offset += int64(len(line))
+	}
+
+	o.size = offset
+	o.cursor = cursor
+	if o.cursor == o.size && o.size > 0 {
+		return o.reset()
+	}
+	return nil
+}
+
+// reset усекает полностью опубликованную очередь.
+func (o *Outbox) reset() error {
+	if err := o.file.Truncate(0); err != nil {
+		return fmt.Errorf("truncate outbox: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("sync outbox: %w", err)
+	}
+	o.size, o.cursor, o.count = 0, 0, 0
+	clear(o.pending)
+	return writeCursor(o.cursorPath(), 0)
+}
+
+// release уменьшает число ожидающих записей с ключом key.
+func (o *Outbox) release(key string) {
+	if o.pending[key]--; o.pending[key] <= 0 {
+		delete(o.pending, key)
+	}
+}
+
+func (o *Outbox) signal() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (o *Outbox) cursorPath() string { return o.deps.Cfg.Path + cursorSuffix }
+
+func readCursor(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read outbox cursor: %w", err)
+	}
+	cursor, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil || cursor < 0 {
+		return 0, fmt.Errorf("%w: cursor %q", ErrCorruptRecord, data)
+	}
+	return cursor, nil
+}
+
+// appendRejected дописывает запись в файл отклонённых записей.
+func appendRejected(path string, line []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return fmt.Errorf("open rejected outbox records: %w", err)
+	}
+	_, err = file.Write(line)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write rejected outbox record: %w", err)
+	}
+	return nil
+}
+
+// writeCursor атомарно заменяет файл курсора.
+func writeCursor(path string, cursor int64) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(cursor, 10)), 0o640); err != nil {
+		return fmt.Errorf("write outbox cursor: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("replace outbox cursor: %w", err)
+	}
+	return nil
+}
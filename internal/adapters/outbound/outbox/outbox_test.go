@@ -0,0 +1,219 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/outbox"
+	"github.com/DENFNC/devPractice/internal/events"
+)
+
+func newTestOutbox(t *testing.T, cfg *config.OutboxConfig) *outbox.Outbox {
+	t.Helper()
+
+	box := outbox.NewOutbox(&outbox.OutboxDeps{
+		Cfg: cfg,
+		Log: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := box.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(func() { _ = box.Stop(context.Background()) })
+	return box
+}
+
+func testConfig(t *testing.T) *config.OutboxConfig {
+	t.Helper()
+	return &config.OutboxConfig{Path: filepath.Join(t.TempDir(), "outbox.log")}
+}
+
+func testMessage(key, value string) events.Message {
+	return events.Message{Topic: events.TopicMessages, Key: []byte(key), Value: []byte(value)}
+}
+
+func mustAppend(t *testing.T, box *outbox.Outbox, msgs ...events.Message) {
+	t.Helper()
+	for _, msg := range msgs {
+		if err := box.Append(context.Background(), msg); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+func mustPeek(t *testing.T, box *outbox.Outbox) outbox.Entry {
+	t.Helper()
+	entry, ok, err := box.Peek()
+	if err != nil || !ok {
+		t.Fatalf("Peek() = %v, %v, want entry", ok, err)
+	}
+	return entry
+}
+
+func TestOutboxRecover(t *testing.T) {
+	cfg := testConfig(t)
+
+	box := newTestOutbox(t, cfg)
+	mustAppend(t, box, testMessage("a", `"1"`), testMessage("b", `"2"`), testMessage("a", `"3"`))
+	if err := box.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	box = newTestOutbox(t, cfg)
+	if got := box.Len(); got != 3 {
+		t.Fatalf("Len() = %d, want 3", got)
+	}
+	if !box.Pending([]byte("a")) || !box.Pending([]byte("b")) {
+		t.Fatal("Pending() = false for recovered keys")
+	}
+	if entry := mustPeek(t, box); string(entry.Message.Value) != `"1"` {
+		t.Fatalf("Peek() value = %s, want first record", entry.Message.Value)
+	}
+}
+
+func TestOutboxTruncatesPartialRecord(t *testing.T) {
+	cfg := testConfig(t)
+
+	box := newTestOutbox(t, cfg)
+	mustAppend(t, box, testMessage("a", `"1"`))
+	if err := box.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	valid, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	file, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if _, err := file.WriteString(`{"topic":"messages","value":`); err != nil {
+		t.Fatalf("WriteString() error = %v", err)
+	}
+	_ = file.Close()
+
+	box = newTestOutbox(t, cfg)
+	if got := box.Len(); got != 1 {
+		t.Fatalf("Len() = %d, want 1", got)
+	}
+	info, err := os.Stat(cfg.Path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size() != int64(len(valid)) {
+		t.Fatalf("file size = %d, want %d", info.Size(), len(valid))
+	}
+
+	mustAppend(t, box, testMessage("b", `"2"`))
+	entry := mustPeek(t, box)
+	if err := box.Ack(entry); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if entry = mustPeek(t, box); string(entry.Message.Value) != `"2"` {
+		t.Fatalf("Peek() value = %s, want record appended after truncation", entry.Message.Value)
+	}
+}
+
+func TestOutboxCursor(t *testing.T) {
+	cfg := testConfig(t)
+
+	box := newTestOutbox(t, cfg)
+	mustAppend(t, box, testMessage("a", `"1"`), testMessage("a", `"2"`))
+
+	first := mustPeek(t, box)
+	if err := box.Ack(first); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	if err := box.Ack(first); !errors.Is(err, outbox.ErrCorruptRecord) {
+		t.Fatalf("repeated Ack() error = %v, want %v", err, outbox.ErrCorruptRecord)
+	}
+
+	cursor, err := os.ReadFile(cfg.Path + ".cursor")
+	if err != nil {
+		t.Fatalf("read cursor error = %v", err)
+	}
+	if string(cursor) != strconv.FormatInt(first.Next, 10) {
+		t.Fatalf("cursor = %s, want %d", cursor, first.Next)
+	}
+	if err := box.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	box = newTestOutbox(t, cfg)
+	if got := box.Len(); got != 1 {
+		t.Fatalf("Len() after restart = %d, want 1", got)
+	}
+	second := mustPeek(t, box)
+	if string(second.Message.Value) != `"2"` {
+		t.Fatalf("Peek() value = %s, want record after cursor", second.Message.Value)
+	}
+	if err := box.Ack(second); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+
+	if box.Len() != 0 || box.Pending([]byte("a")) {
+		t.Fatal("drained outbox still reports pending records")
+	}
+	info, err := os.Stat(cfg.Path)
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("drained outbox size = %d, want 0", info.Size())
+	}
+	if cursor, _ := os.ReadFile(cfg.Path + ".cursor"); string(cursor) != "0" {
+		t.Fatalf("drained outbox cursor = %s, want 0", cursor)
+	}
+}
+
+func TestOutboxMaxBytes(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxBytes = 64
+
+	box := newTestOutbox(t, cfg)
+	mustAppend(t, box, testMessage("a", `"1"`))
+	if err := box.Append(context.Background(), testMessage("a", `"2"`)); !errors.Is(err, outbox.ErrFull) {
+		t.Fatalf("Append() error = %v, want %v", err, outbox.ErrFull)
+	}
+	if got := box.Len(); got != 1 {
+		t.Fatalf("Len() = %d, want 1", got)
+	}
+
+	if err := box.Ack(mustPeek(t, box)); err != nil {
+		t.Fatalf("Ack() error = %v", err)
+	}
+	mustAppend(t, box, testMessage("a", `"2"`))
+}
+
+func TestOutboxReject(t *testing.T) {
+	cfg := testConfig(t)
+
+	box := newTestOutbox(t, cfg)
+	mustAppend(t, box, testMessage("a", `"1"`), testMessage("a", `"2"`))
+
+	line, err := box.Reject()
+	if err != nil {
+		t.Fatalf("Reject() error = %v", err)
+	}
+	rejected, err := os.ReadFile(cfg.Path + ".rejected")
+	if err != nil {
+		t.Fatalf("read rejected records error = %v", err)
+	}
+	if string(rejected) != line {
+		t.Fatalf("rejected records = %q, want %q", rejected, line)
+	}
+
+	if got := box.Len(); got != 1 {
+		t.Fatalf("Len() = %d, want 1", got)
+	}
+	if entry := mustPeek(t, box); string(entry.Message.Value) != `"2"` {
+		t.Fatalf("Peek() value = %s, want record after the rejected one", entry.Message.Value)
+	}
+}
@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/DENFNC/devPractice/pkg/retry"
+)
+
+// Publisher синхронно публикует событие и возвращает ошибку, только если
+// брокер его не принял. Ошибка, которую не исправит повтор, должна
+// оборачивать events.ErrUndeliverable.
+type Publisher interface {
+	Publish(ctx context.Context, msg events.Message) error
+}
+
+// Relay переносит события из очереди в брокер. Записи публикуются по одной
+// в порядке добавления; при ошибке публикации Relay ждёт по стратегии
+// Backoff и повторяет ту же запись, не переходя к следующим, сколько бы ни
+// длилась недоступность брокера: клиент уже получил ответ queued. Только
+// запись, которую брокер не примет никогда, переносится в файл отклонённых
+// записей через Outbox.Reject.
+type Relay struct {
+	outbox    *Outbox
+	publisher Publisher
+	backoff   *retry.Backoff
+	log       *slog.Logger
+}
+
+// RelayDeps содержит зависимости Relay.
+type RelayDeps struct {
+	Outbox    *Outbox
+	Publisher Publisher
+	Backoff   *config.RetryConfig
+	Log       *slog.Logger
+}
+
+// NewRelay валидирует зависимости и возвращает Relay.
+func NewRelay(deps *RelayDeps) *Relay {
+	if deps == nil || deps.Outbox == nil {
+		panic("outbox cannot be nil")
+	}
+	if deps.Publisher == nil {
+		panic("publisher cannot be nil")
+	}
+	if deps.Log == nil {
+		panic("logger cannot be nil")
+	}
+
+	return &Relay{
+		outbox:    deps.Outbox,
+		publisher: deps.Publisher,
+		backoff:   retry.NewBackoff(deps.Backoff),
+		log:       deps.Log,
+	}
+}
+
+// Run разбирает очередь до отмены контекста. Когда очередь пуста, Relay
+// ждёт сигнала о новой записи.
+func (r *Relay) Run(ctx context.Context) {
+	attempts := 0
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		entry, ok, err := r.outbox.Peek()
+		switch {
+		case errors.Is(err, ErrCorruptRecord):
+			line, skipErr := r.outbox.Skip()
+			r.log.Error("Outbox record skipped",
+				slog.String("error", err.Error()),
+				slog.String("record", line),
+				slog.Any("skip_error", skipErr),
+			)
+			continue
+		case err != nil:
+			r.log.Error("Outbox read failed", slog.String("error", err.Error()))
+			r.backoff.Sleep(ctx)
+			continue
+		case !ok:
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.outbox.Notify():
+			}
+			continue
+		}
+
+		if err := r.publisher.Publish(ctx, entry.Message); err != nil {
+			if errors.Is(err, events.ErrUndeliverable) {
+				if r.reject(err) {
+					attempts = 0
+					continue
+				}
+				r.backoff.Sleep(ctx)
+				continue
+			}
+			attempts++
+			r.log.Warn("Outbox publish failed",
+				slog.String("topic", entry.Message.Topic),
+				slog.Int("attempt", attempts),
+				slog.Int("pending", r.outbox.Len()),
+				slog.String("error", err.Error()),
+			)
+			r.backoff.Sleep(ctx)
+			continue
+		}
+		attempts = 0
+		r.backoff.Reset()
+
+		if err := r.outbox.Ack(entry); err != nil {
+			// Запись опубликована, но курсор не сохранён: после перезапуска она
+			// уйдёт повторно, дубликат отсекут потребители.
+			r.log.Error("Outbox ack failed", slog.String("error", err.Error()))
+			r.backoff.Sleep(ctx)
+			continue
+		}
+		if r.outbox.Len() == 0 {
+			r.log.Info("Outbox drained")
+		}
+	}
+}
+
+// reject переносит запись в начале очереди, которую брокер не примет, в
+// файл отклонённых записей. Возвращает false, если перенести не удалось и
+// запись осталась в очереди.
+func (r *Relay) reject(cause error) bool {
+	line, err := r.outbox.Reject()
+	if err != nil {
+		r.log.Error("Outbox record rejection failed",
+			slog.String("error", err.Error()),
+			slog.String("cause", cause.Error()),
+		)
+		return false
+	}
+	r.log.Error("Outbox record rejected",
+		slog.String("error", cause.Error()),
+		slog.String("record", line),
+	)
+	return true
+}
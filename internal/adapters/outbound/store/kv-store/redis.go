@@ -6,12 +6,18 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
+	"github.com/DENFNC/devPractice/internal/events"
 	"github.com/redis/go-redis/v9"
 )
 
+// streamDataField — поле записи потока, в котором хранятся данные.
+const streamDataField = "data"
+
 // Redis инкапсулирует клиента Redis и зависимости, необходимые адаптеру.
 type Redis struct {
 	name   string
@@ -72,22 +78,14 @@ func (r *Redis) Start(ctx context.Context) error {
 	return nil
 }
 
-// Stop закрывает соединение и очищает БД в best-effort режиме.
-func (r *Redis) Stop(ctx context.Context) error {
+// Stop закрывает соединение. База не очищается: её разделяют все экземпляры
+// шлюза, и каждый узел удаляет только собственные сессии.
+func (r *Redis) Stop(_ context.Context) error {
 	defer r.deps.Log.Debug(
 		"Redis connection closed",
 		slog.String("addr", r.deps.Cfg.Address),
 		slog.Int("DB", r.deps.Cfg.DB),
 	)
-	if err := r.FlushAsync(ctx); err != nil {
-		r.deps.Log.Error(
-			"failed to flush db redis",
-			slog.String("addr", r.deps.Cfg.Address),
-			slog.Int("DB", r.deps.Cfg.DB),
-			slog.String("error", err.Error()),
-		)
-		return fmt.Errorf("redis flush async: %w", err)
-	}
 	if err := r.client.Close(); err != nil {
 		r.deps.Log.Error(
 			"failed to close redis connection",
@@ -114,6 +112,20 @@ func (r *Redis) Add(ctx context.Context, key string, value any, expiration time.
 	return nil
 }
 
+// AddIfAbsent записывает значение с TTL, только если ключ ещё не существует
+// (SET NX). Возвращает false, если ключ уже был занят.
+func (r *Redis) AddIfAbsent(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	if expiration < 0 {
+		return false, ErrNegativeTTL
+	}
+
+	added, err := r.client.SetNX(ctx, key, value, expiration).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis set nx %q: %w", key, err)
+	}
+	return added, nil
+}
+
 // Get возвращает значение ключа.
 func (r *Redis) Get(ctx context.Context, key string) (string, error) {
 	result, err := r.client.Get(ctx, key).Result()
@@ -127,6 +139,15 @@ func (r *Redis) Get(ctx context.Context, key string) (string, error) {
 	return result, nil
 }
 
+// Exists сообщает, существует ли ключ.
+func (r *Redis) Exists(ctx context.Context, key string) (bool, error) {
+	n, err := r.client.Exists(ctx, key).Result()
+	if err != nil {
+		return false, fmt.Errorf("redis exists %q: %w", key, err)
+	}
+	return n > 0, nil
+}
+
 // Remove удаляет набор ключей.
 func (r *Redis) Remove(ctx context.Context, keys ...string) error {
 	if len(keys) == 0 {
@@ -139,6 +160,353 @@ func (r *Redis) Remove(ctx context.Context, keys ...string) error {
 	return nil
 }
 
+// addMemberScript добавляет элемент в sorted set, где score — момент истечения
+// в миллисекундах (или +inf для бессрочных элементов). Попутно удаляет
+// истёкшие элементы и выставляет TTL ключа по самому позднему элементу.
+var addMemberScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[3])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if last[2] == 'inf' then
+	redis.call('PERSIST', KEYS[1])
+else
+	redis.call('PEXPIREAT', KEYS[1], last[2])
+end
+return 1
+`)
+
+// membersScript удаляет истёкшие элементы и возвращает оставшиеся.
+var membersScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+return redis.call('ZRANGE', KEYS[1], 0, -1)
+`)
+
+// AddMember атомарно добавляет элемент в множество key. Каждый элемент
+// хранит собственный срок жизни; expiration равный нулю означает бессрочный
+// элемент. Повторное добавление продлевает срок жизни.
+func (r *Redis) AddMember(ctx context.Context, key, member string, expiration time.Duration) error {
+	if expiration < 0 {
+		return ErrNegativeTTL
+	}
+
+	now := time.Now()
+	score := "+inf"
+	if expiration > 0 {
+		score = strconv.FormatInt(now.Add(expiration).UnixMilli(), 10)
+	}
+
+	err := addMemberScript.Run(ctx, r.client, []string{key}, member, score, now.UnixMilli()).Err()
+	if err != nil {
+		return fmt.Errorf("redis add member %q to %q: %w", member, key, err)
+	}
+	return nil
+}
+
+// RemoveMember удаляет элемент из множества key. Пустое множество удаляется
+// Redis автоматически.
+func (r *Redis) RemoveMember(ctx context.Context, key, member string) error {
+	if err := r.client.ZRem(ctx, key, member).Err(); err != nil {
+		return fmt.Errorf("redis remove member %q from %q: %w", member, key, err)
+	}
+	return nil
+}
+
+// Members возвращает неистёкшие элементы множества key. Для отсутствующего
+// ключа возвращается пустой срез.
+func (r *Redis) Members(ctx context.Context, key string) ([]string, error) {
+	members, err := membersScript.Run(ctx, r.client, []string{key}, time.Now().UnixMilli()).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("redis members %q: %w", key, err)
+	}
+	return members, nil
+}
+
+// PushCapped добавляет value в конец списка key, оставляя не больше maxLen
+// последних элементов, и продлевает TTL списка. maxLen равный нулю не
+// ограничивает длину, expiration равный нулю не меняет TTL.
+func (r *Redis) PushCapped(ctx context.Context, key, value string, maxLen int64, expiration time.Duration) error {
+	if expiration < 0 {
+		return ErrNegativeTTL
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, value)
+		if maxLen > 0 {
+			pipe.LTrim(ctx, key, -maxLen, -1)
+		}
+		if expiration > 0 {
+			pipe.PExpire(ctx, key, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis push to %q: %w", key, err)
+	}
+	return nil
+}
+
+// Range возвращает все элементы списка key по порядку. Для отсутствующего
+// ключа возвращается пустой срез.
+func (r *Redis) Range(ctx context.Context, key string) ([]string, error) {
+	values, err := r.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis range %q: %w", key, err)
+	}
+	return values, nil
+}
+
+// popHeadIfScript удаляет первый элемент списка KEYS[1], только если он
+// равен ARGV[1].
+var popHeadIfScript = redis.NewScript(`
+if redis.call('LINDEX', KEYS[1], 0) == ARGV[1] then
+	redis.call('LPOP', KEYS[1])
+	return 1
+end
+return 0
+`)
+
+// PopHeadIf атомарно удаляет первый элемент списка key, если он равен value,
+// и сообщает, был ли он удалён. Так элемент, который уже удалили другой
+// читатель или ограничение длины в PushCapped, не заменяется соседним.
+func (r *Redis) PopHeadIf(ctx context.Context, key, value string) (bool, error) {
+	popped, err := popHeadIfScript.Run(ctx, r.client, []string{key}, value).Int()
+	if err != nil {
+		return false, fmt.Errorf("redis pop head of %q: %w", key, err)
+	}
+	return popped == 1, nil
+}
+
+// RemoveValue удаляет из списка key первое вхождение value.
+func (r *Redis) RemoveValue(ctx context.Context, key, value string) error {
+	if err := r.client.LRem(ctx, key, 1, value).Err(); err != nil {
+		return fmt.Errorf("redis remove value from %q: %w", key, err)
+	}
+	return nil
+}
+
+// hashMaxScript записывает в поля хеша значения из аргументов, только если
+// они больше текущих при строковом сравнении, продлевает TTL ключа (если
+// последний аргумент положителен) и возвращает хеш целиком.
+var hashMaxScript = redis.NewScript(`
+for i = 1, #ARGV - 1, 2 do
+	local current = redis.call('HGET', KEYS[1], ARGV[i])
+	if not current or current < ARGV[i + 1] then
+		redis.call('HSET', KEYS[1], ARGV[i], ARGV[i + 1])
+	end
+end
+if tonumber(ARGV[#ARGV]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[#ARGV])
+end
+return redis.call('HGETALL', KEYS[1])
+`)
+
+// HashMax атомарно поднимает поля хеша key до переданных значений: поле
+// меняется, только если новое значение больше текущего при строковом
+// сравнении. Возвращает состояние хеша после обновления. expiration равный
+// нулю не меняет TTL.
+func (r *Redis) HashMax(ctx context.Context, key string, fields map[string]string, expiration time.Duration) (map[string]string, error) {
+	if expiration < 0 {
+		return nil, ErrNegativeTTL
+	}
+
+	args := make([]any, 0, len(fields)*2+1)
+	for field, value := range fields {
+		args = append(args, field, value)
+	}
+	args = append(args, expiration.Milliseconds())
+
+	values, err := hashMaxScript.Run(ctx, r.client, []string{key}, args...).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("redis hash max %q: %w", key, err)
+	}
+
+	hash := make(map[string]string, len(values)/2)
+	for i := 0; i+1 < len(values); i += 2 {
+		hash[values[i]] = values[i+1]
+	}
+	return hash, nil
+}
+
+// HashSet записывает поля хеша key и продлевает его TTL на expiration.
+// Нулевой expiration не меняет TTL.
+func (r *Redis) HashSet(ctx context.Context, key string, fields map[string]string, expiration time.Duration) error {
+	if expiration < 0 {
+		return ErrNegativeTTL
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, fields)
+		if expiration > 0 {
+			pipe.PExpire(ctx, key, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis hash set %q: %w", key, err)
+	}
+	return nil
+}
+
+// HashGetAll возвращает все поля хеша key. Для отсутствующего ключа
+// возвращается пустая карта.
+func (r *Redis) HashGetAll(ctx context.Context, key string) (map[string]string, error) {
+	hash, err := r.client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis hash get all %q: %w", key, err)
+	}
+	return hash, nil
+}
+
+// AddStream добавляет data в поток key и возвращает идентификатор записи.
+// Поток ограничивается примерно maxLen последними записями (MAXLEN ~), а
+// его TTL продлевается на expiration. Нулевые значения не ограничивают
+// длину и не меняют TTL.
+func (r *Redis) AddStream(ctx context.Context, key string, data []byte, maxLen int64, expiration time.Duration) (string, error) {
+	if expiration < 0 {
+		return "", ErrNegativeTTL
+	}
+
+	var add *redis.StringCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		add = pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: key,
+			MaxLen: maxLen,
+			Approx: maxLen > 0,
+			Values: []string{streamDataField, string(data)},
+		})
+		if expiration > 0 {
+			pipe.PExpire(ctx, key, expiration)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("redis add to stream %q: %w", key, err)
+	}
+	return add.Val(), nil
+}
+
+// addStreamOnceScript добавляет запись в поток KEYS[1], только если
+// идентификатор для KEYS[2] ещё не назначен, и запоминает его. ARGV: данные,
+// maxLen, поле данных, TTL в миллисекундах.
+var addStreamOnceScript = redis.NewScript(`
+local id = redis.call('GET', KEYS[2])
+if id then
+	return id
+end
+if tonumber(ARGV[2]) > 0 then
+	id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[2], '*', ARGV[3], ARGV[1])
+else
+	id = redis.call('XADD', KEYS[1], '*', ARGV[3], ARGV[1])
+end
+if tonumber(ARGV[4]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[4])
+	redis.call('SET', KEYS[2], id, 'PX', ARGV[4])
+else
+	redis.call('SET', KEYS[2], id)
+end
+return id
+`)
+
+// AddStreamOnce добавляет data в поток key, как AddStream, если под ключом
+// idKey ещё нет идентификатора записи, и сохраняет его там на expiration.
+// Иначе возвращает ранее назначенный идентификатор, не добавляя запись.
+func (r *Redis) AddStreamOnce(ctx context.Context, key, idKey string, data []byte, maxLen int64, expiration time.Duration) (string, error) {
+	if expiration < 0 {
+		return "", ErrNegativeTTL
+	}
+
+	id, err := addStreamOnceScript.Run(ctx, r.client, []string{key, idKey},
+		string(data), maxLen, streamDataField, expiration.Milliseconds()).Text()
+	if err != nil {
+		return "", fmt.Errorf("redis add once to stream %q: %w", key, err)
+	}
+	return id, nil
+}
+
+// RangeStream возвращает записи потока key в диапазоне [start, stop] в
+// синтаксисе XRANGE: "-" и "+" — границы потока, "(" перед идентификатором
+// исключает его. count равный нулю не ограничивает число записей.
+func (r *Redis) RangeStream(ctx context.Context, key, start, stop string, count int64) ([]events.StreamEntry, error) {
+	var (
+		messages []redis.XMessage
+		err      error
+	)
+	if count > 0 {
+		messages, err = r.client.XRangeN(ctx, key, start, stop, count).Result()
+	} else {
+		messages, err = r.client.XRange(ctx, key, start, stop).Result()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis range stream %q: %w", key, err)
+	}
+
+	entries := make([]events.StreamEntry, 0, len(messages))
+	for _, msg := range messages {
+		data, _ := msg.Values[streamDataField].(string)
+		entries = append(entries, events.StreamEntry{ID: msg.ID, Data: []byte(data)})
+	}
+	return entries, nil
+}
+
+// StreamMaxDeletedID возвращает наибольший идентификатор записи, удалённой
+// из потока key, по XINFO STREAM (Redis 7+); "0-0" означает, что удалений
+// не было. Пустая строка возвращается, если потока нет или сервер не
+// сообщает это значение.
+func (r *Redis) StreamMaxDeletedID(ctx context.Context, key string) (string, error) {
+	info, err := r.client.XInfoStream(ctx, key).Result()
+	if err != nil {
+		if strings.Contains(err.Error(), "no such key") {
+			return "", nil
+		}
+		return "", fmt.Errorf("redis stream info %q: %w", key, err)
+	}
+	return info.MaxDeletedEntryID, nil
+}
+
+// Publish отправляет сообщение в pub/sub-канал и возвращает число
+// подписчиков, получивших его. Ноль означает, что канал никто не слушает.
+func (r *Redis) Publish(ctx context.Context, channel string, payload []byte) (int64, error) {
+	receivers, err := r.client.Publish(ctx, channel, payload).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis publish %q: %w", channel, err)
+	}
+	return receivers, nil
+}
+
+// Subscribe подписывается на pub/sub-канал и вызывает handler для каждого
+// сообщения. Блокируется до отмены контекста.
+func (r *Redis) Subscribe(ctx context.Context, channel string, handler func(ctx context.Context, payload []byte)) error {
+	pubsub := r.client.Subscribe(ctx, channel)
+	defer func() {
+		if err := pubsub.Close(); err != nil {
+			r.deps.Log.Warn("failed to close redis subscription",
+				slog.String("channel", channel),
+				slog.String("error", err.Error()),
+			)
+		}
+	}()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("redis subscribe %q: %w", channel, err)
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handler(ctx, []byte(msg.Payload))
+		}
+	}
+}
+
 // ScanKeys ищет ключи по шаблону и возвращает их значения.
 func (r *Redis) ScanKeys(ctx context.Context, match string, step int64) (map[string]string, error) {
 	iter := r.client.Scan(ctx, 0, match, step).Iterator()
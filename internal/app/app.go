@@ -6,15 +6,21 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/DENFNC/devPractice/internal/adapters/inbound/handlers"
 	"github.com/DENFNC/devPractice/internal/adapters/inbound/ws"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/auth"
 	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
 	"github.com/DENFNC/devPractice/internal/adapters/outbound/kafka"
+	"github.com/DENFNC/devPractice/internal/adapters/outbound/outbox"
 	kvstore "github.com/DENFNC/devPractice/internal/adapters/outbound/store/kv-store"
 	"github.com/DENFNC/devPractice/internal/app/happ"
+	"github.com/DENFNC/devPractice/internal/events"
 	"github.com/DENFNC/devPractice/internal/usecases"
+	"github.com/google/uuid"
 )
 
 // App объединяет инфраструктурные адаптеры с HTTP-сервером и управляет их жизненным циклом.
@@ -24,12 +30,15 @@ type App struct {
 	happ      *happ.HTTPServer
 	container *Container
 	kafka     *kafka.Kafka
+	registry  *ws.Registry
 
 	startOnce    sync.Once
 	shutdownOnce sync.Once
 	wg           sync.WaitGroup
 
 	consumerCancel context.CancelFunc
+	kafkaCancel    context.CancelFunc
+	kafkaDone      chan struct{}
 }
 
 // Deps описывает зависимости, необходимые для сборки приложения.
@@ -40,31 +49,87 @@ type Deps struct {
 
 // New собирает компоненты, запускает инфраструктурные адаптеры и возвращает готовый экземпляр.
 func New(deps *Deps) *App {
-	container, store, kfk := initInfrastructure(deps)
+	nodeID := initNodeID(deps)
+	container, store, kfk, box, verifier := initInfrastructure(deps)
 
-	router, consumerCtx, consumerCancel := initMessaging(deps, store, kfk)
+	inbox := ws.NewInbox(&ws.InboxDeps{
+		Store:  store,
+		MaxLen: int64(messageConfig(deps).InboxMaxLen),
+		TTL:    messageConfig(deps).InboxTTL,
+	})
 
-	hserver := happ.New(&happ.ServerDeps{
-		Log:    deps.Log,
-		Cfg:    deps.Cfg.HTTPConfig,
+	eventLog := ws.NewEventLog(&ws.EventLogDeps{
 		Store:  store,
-		Router: router,
+		MaxLen: int64(messageConfig(deps).StreamMaxLen),
+		TTL:    messageConfig(deps).StreamTTL,
 	})
 
+	router, notifier, consumerCtx, consumerCancel := initMessaging(deps, store, kfk, box, inbox, eventLog)
+
+	registry := ws.NewRegistry(&ws.RegistryDeps{
+		Store:  store,
+		NodeID: nodeID,
+		Cfg:    deps.Cfg.WebSocketConfig,
+	})
+
+	hserver := happ.New(&happ.ServerDeps{
+		Log:      deps.Log,
+		Cfg:      deps.Cfg.HTTPConfig,
+		WSCfg:    deps.Cfg.WebSocketConfig,
+		Registry: registry,
+		Router:   router,
+		Verifier: verifier,
+		Inbox:    inbox,
+		Events:   eventLog,
+	})
+
+	kafkaCtx, kafkaCancel := context.WithCancel(consumerCtx)
 	app := &App{
 		deps:           deps,
 		container:      container,
 		happ:           hserver,
 		kafka:          kfk,
+		registry:       registry,
 		consumerCancel: consumerCancel,
+		kafkaCancel:    kafkaCancel,
+		kafkaDone:      make(chan struct{}),
 	}
 
 	app.wg.Add(1)
 	go func() {
 		defer app.wg.Done()
-		kfk.StartConsuming(consumerCtx)
+		registry.Run(consumerCtx)
+	}()
+
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		defer close(app.kafkaDone)
+		kfk.StartConsuming(kafkaCtx)
 	}()
 
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		if err := notifier.ListenRemote(consumerCtx); err != nil {
+			deps.Log.Error("Node relay stopped", slog.String("error", err.Error()))
+		}
+	}()
+
+	if box != nil {
+		relay := outbox.NewRelay(&outbox.RelayDeps{
+			Outbox:    box,
+			Publisher: kfk,
+			Backoff:   &deps.Cfg.OutboxConfig.RelayBackoff,
+			Log:       deps.Log,
+		})
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			relay.Run(consumerCtx)
+		}()
+	}
+
 	return app
 }
 
@@ -79,7 +144,10 @@ func (a *App) StartAsync() {
 	})
 }
 
-// Shutdown корректно останавливает HTTP-сервер и все зарегистрированные компоненты.
+// Shutdown корректно останавливает приложение: сначала останавливается
+// потребитель Kafka, затем шлюз закрывает WebSocket-сессии и дожидается их
+// обработчиков, после этого — фоновые задачи, и только потом — Redis, Kafka
+// и остальные компоненты.
 func (a *App) Shutdown(ctx context.Context) error {
 	if ctx == nil {
 		return errors.New("app shutdown: context is nil")
@@ -93,24 +161,95 @@ func (a *App) Shutdown(ctx context.Context) error {
 
 	var errs []error
 	a.shutdownOnce.Do(func() {
+		if err := a.stopConsuming(ctx); err != nil {
+			errs = append(errs, err)
+		}
+		if err := a.happ.Stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+
 		if a.consumerCancel != nil {
 			a.consumerCancel()
 		}
-		if err := a.happ.Stop(ctx); err != nil {
+		if err := a.waitBackground(ctx); err != nil {
 			errs = append(errs, err)
 		}
+
+		if err := a.registry.Leave(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("leave session registry: %w", err))
+		}
 		if err := a.container.StopAll(ctx); err != nil {
 			errs = append(errs, err)
 		}
-		a.wg.Wait()
 	})
 
 	return errors.Join(errs...)
 }
 
-func initInfrastructure(deps *Deps) (*Container, *kvstore.Redis, *kafka.Kafka) {
+// stopConsuming останавливает потребитель Kafka и ждёт фиксации оффсетов
+// обработанных сообщений. Потребитель останавливается до закрытия сессий:
+// иначе доставки в закрывающиеся сессии были бы зафиксированы и потеряны.
+// Непрочитанные сообщения после ребалансировки получат другие узлы группы.
+func (a *App) stopConsuming(ctx context.Context) error {
+	a.kafkaCancel()
+
+	select {
+	case <-a.kafkaDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("stop kafka consumer: %w", ctx.Err())
+	}
+}
+
+// waitBackground ждёт завершения фоновых горутин приложения, в том числе
+// доставки сообщений, полученных из Kafka до отмены потребителя.
+func (a *App) waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait background workers: %w", ctx.Err())
+	}
+}
+
+// initNodeID возвращает идентификатор экземпляра шлюза из конфигурации либо
+// генерирует его из имени хоста и случайного суффикса.
+func initNodeID(deps *Deps) string {
+	if deps.Cfg.AppConfig == nil {
+		deps.Cfg.AppConfig = &config.AppConfig{}
+	}
+	if deps.Cfg.NodeID != "" {
+		return deps.Cfg.NodeID
+	}
+
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "gateway"
+	}
+	deps.Cfg.NodeID = host + "-" + uuid.NewString()[:8]
+
+	deps.Log.Info("Generated gateway node id", slog.String("node_id", deps.Cfg.NodeID))
+	return deps.Cfg.NodeID
+}
+
+// initInfrastructure создаёт и запускает инфраструктурные компоненты.
+// Outbox добавляется в контейнер первым и поэтому закрывается последним:
+// события, которые Kafka не смогла записать при финальном сбросе
+// асинхронного продюсера, ещё сохраняются в него.
+func initInfrastructure(deps *Deps) (*Container, *kvstore.Redis, *kafka.Kafka, *outbox.Outbox, ws.TokenVerifier) {
 	container := NewContainer(deps.Log, deps.Cfg)
 
+	box := initOutbox(deps)
+	if box != nil {
+		container.Add(box)
+	}
+
 	store := kvstore.NewRedis(&kvstore.RedisDeps{
 		Log: deps.Log,
 		Cfg: deps.Cfg.RedisConfig,
@@ -124,32 +263,138 @@ func initInfrastructure(deps *Deps) (*Container, *kvstore.Redis, *kafka.Kafka) {
 	defer cancel()
 
 	container.Add(store, kfk)
+
+	verifier := initVerifier(deps)
+	if component, ok := verifier.(Component); ok {
+		container.Add(component)
+	}
+
 	if err := container.StartAll(ctx); err != nil {
 		deps.Log.Error("Failed to start infrastructure components after multiple retries", slog.String("error", err.Error()))
 		panic(fmt.Errorf("start components: %w", err))
 	}
 
-	return container, store, kfk
+	return container, store, kfk, box, verifier
+}
+
+// initOutbox создаёт локальную очередь неопубликованных сообщений, если
+// она включена.
+func initOutbox(deps *Deps) *outbox.Outbox {
+	cfg := deps.Cfg.OutboxConfig
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	return outbox.NewOutbox(&outbox.OutboxDeps{
+		Cfg: cfg,
+		Log: deps.Log,
+	})
+}
+
+func initVerifier(deps *Deps) ws.TokenVerifier {
+	cfg := deps.Cfg.AuthConfig
+	if cfg == nil {
+		panic("auth config cannot be nil")
+	}
+
+	switch cfg.Mode {
+	case "", "jwt":
+		return auth.NewJWTVerifier(cfg)
+	case "grpc":
+		return auth.NewGRPCVerifier(&auth.GRPCVerifierDeps{
+			Cfg: cfg,
+			Log: deps.Log,
+		})
+	case "static":
+		deps.Log.Warn("Static token verifier is enabled, do not use it in production")
+		return auth.NewStaticVerifier(cfg.StaticTokens)
+	default:
+		panic(fmt.Errorf("%w: %q", auth.ErrUnknownMode, cfg.Mode))
+	}
+}
+
+// usecaseOutbox не даёт nil-указателю превратиться в непустой интерфейс.
+func usecaseOutbox(box *outbox.Outbox) usecases.Outbox {
+	if box == nil {
+		return nil
+	}
+	return box
+}
+
+// messageConfig возвращает секцию message с значениями по умолчанию, если
+// она отсутствует в конфигурации.
+func messageConfig(deps *Deps) *config.MessageConfig {
+	if deps.Cfg.MessageConfig == nil {
+		deps.Cfg.MessageConfig = &config.MessageConfig{
+			IdempotencyTTL:  24 * time.Hour,
+			DedupeTTL:       time.Hour,
+			InboxMaxLen:     256,
+			InboxTTL:        7 * 24 * time.Hour,
+			StreamMaxLen:    200,
+			StreamTTL:       24 * time.Hour,
+			ReceiptTTL:      30 * 24 * time.Hour,
+			ParticipantsTTL: 72 * time.Hour,
+		}
+	}
+	return deps.Cfg.MessageConfig
 }
 
 func initMessaging(
 	deps *Deps,
 	store *kvstore.Redis,
 	kfk *kafka.Kafka,
-) (*ws.HandlerChain, context.Context, context.CancelFunc) {
+	box *outbox.Outbox,
+	inbox *ws.Inbox,
+	eventLog *ws.EventLog,
+) (*ws.HandlerChain, *ws.Notifier, context.Context, context.CancelFunc) {
 	router := ws.NewHandlerChain()
-	notifier := ws.NewNotifier(store)
+	notifier := ws.NewNotifier(&ws.NotifierDeps{
+		Store:  store,
+		Bus:    store,
+		Inbox:  inbox,
+		Events: eventLog,
+		NodeID: deps.Cfg.NodeID,
+	})
 
-	usecase := usecases.NewMessageUsecase(kfk, notifier)
-	kfk.Handle(deps.Cfg.TestTopic, usecase.HandleDelivery)
+	usecase := usecases.NewMessageUsecase(&usecases.MessageUsecaseDeps{
+		Eventbus:       kfk,
+		Notifier:       notifier,
+		Idempotency:    store,
+		IdempotencyTTL: messageConfig(deps).IdempotencyTTL,
+		Dedupe:         store,
+		DedupeTTL:      messageConfig(deps).DedupeTTL,
+		NodeID:         deps.Cfg.NodeID,
+		Outbox:         usecaseOutbox(box),
+		Messages:       store,
+		MessageTTL:     messageConfig(deps).ParticipantsTTL,
+		AsyncPublish:   deps.Cfg.KafkaConfig.Producer.Async,
+		Log:            deps.Log,
+	})
+	kfk.Handle(events.TopicMessages, usecase.HandleDelivery)
+	kfk.HandleCompletion(usecase.HandlePublished)
+
+	receipts := usecases.NewReceiptUsecase(&usecases.ReceiptUsecaseDeps{
+		Eventbus:     kfk,
+		Notifier:     notifier,
+		Store:        store,
+		TTL:          messageConfig(deps).ReceiptTTL,
+		MessageTTL:   messageConfig(deps).ParticipantsTTL,
+		NodeID:       deps.Cfg.NodeID,
+		AsyncPublish: deps.Cfg.KafkaConfig.Producer.Async,
+	})
+	kfk.Handle(events.TopicReceipts, receipts.HandleReceipt)
 
 	handlers.NewSendMessageHandler(&handlers.MessageHandlerDeps{
 		Usecase: usecase,
 		Router:  router,
 		Store:   store,
 	})
+	handlers.NewReceiptHandler(&handlers.ReceiptHandlerDeps{
+		Usecase: receipts,
+		Router:  router,
+	})
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return router, ctx, cancel
+	return router, notifier, ctx, cancel
 }
@@ -18,8 +18,10 @@ type Component interface {
 }
 
 // Container хранит набор компонентов и управляет их жизненным циклом.
+// Компоненты запускаются в порядке добавления и останавливаются в обратном.
 type Container struct {
 	comps    map[string]Component
+	order    []string
 	log      *slog.Logger
 	retryCfg *config.RetryConfig
 }
@@ -40,6 +42,9 @@ func NewContainer(log *slog.Logger, cfg *config.Config) *Container {
 // Add добавляет один или несколько компонентов в контейнер.
 func (c *Container) Add(comps ...Component) {
 	for _, comp := range comps {
+		if _, exists := c.comps[comp.Name()]; !exists {
+			c.order = append(c.order, comp.Name())
+		}
 		c.comps[comp.Name()] = comp
 	}
 }
@@ -47,8 +52,8 @@ func (c *Container) Add(comps ...Component) {
 // StartAll последовательно запускает компоненты, накапливая ошибки запуска.
 func (c *Container) StartAll(ctx context.Context) error {
 	var errs []error
-	for _, comp := range c.comps {
-		component := c.comps[comp.Name()]
+	for _, name := range c.order {
+		component := c.comps[name]
 		attempt := 0
 
 		err := retry.Do(ctx, c.retryCfg, func(ctx context.Context) error {
@@ -83,9 +88,10 @@ func (c *Container) StartAll(ctx context.Context) error {
 // StopAll останавливает компоненты в обратном порядке, обеспечивая корректное завершение зависимостей.
 func (c *Container) StopAll(ctx context.Context) error {
 	var errs []error
-	for i, comp := range c.comps {
-		if err := c.comps[comp.Name()].Stop(ctx); err != nil {
-			errs = append(errs, fmt.Errorf("%s stop failed: %w", c.comps[i].Name(), err))
+	for i := len(c.order) - 1; i >= 0; i-- {
+		component := c.comps[c.order[i]]
+		if err := component.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s stop failed: %w", component.Name(), err))
 		}
 	}
 	return errors.Join(errs...)
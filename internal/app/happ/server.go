@@ -4,6 +4,7 @@ package happ
 import (
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -13,18 +14,26 @@ import (
 	"github.com/DENFNC/devPractice/internal/adapters/outbound/config"
 )
 
-// HTTPServer инкапсулирует конфигурацию net/http.Server.
+// HTTPServer инкапсулирует конфигурацию net/http.Server. Служебный сервер
+// debug с метриками expvar запускается, только если задан
+// http.debug-address.
 type HTTPServer struct {
-	log    *slog.Logger
-	server *http.Server
+	log     *slog.Logger
+	server  *http.Server
+	debug   *http.Server
+	gateway *websocket.Gateway
 }
 
 // ServerDeps агрегирует зависимости, необходимые для создания сервера.
 type ServerDeps struct {
-	Log    *slog.Logger
-	Cfg    *config.HTTPConfig
-	Router websocket.Router
-	Store  websocket.SessionStore
+	Log      *slog.Logger
+	Cfg      *config.HTTPConfig
+	WSCfg    *config.WebSocketConfig
+	Router   websocket.Router
+	Registry *websocket.Registry
+	Verifier websocket.TokenVerifier
+	Inbox    *websocket.Inbox
+	Events   *websocket.EventLog
 }
 
 // New настраивает HTTP-хендлеры и возвращает готовый сервер.
@@ -40,7 +49,14 @@ func New(deps *ServerDeps) *HTTPServer {
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	gw := websocket.NewGateway(deps.Store, deps.Router)
+	gw := websocket.NewGateway(&websocket.GatewayDeps{
+		Registry: deps.Registry,
+		Router:   deps.Router,
+		Verifier: deps.Verifier,
+		Inbox:    deps.Inbox,
+		Events:   deps.Events,
+		Cfg:      deps.WSCfg,
+	})
 
 	mux.HandleFunc("/realtime/chat", gw.HandleWS)
 
@@ -50,8 +66,26 @@ func New(deps *ServerDeps) *HTTPServer {
 	)
 
 	return &HTTPServer{
-		log:    log,
-		server: server,
+		log:     log,
+		server:  server,
+		debug:   newDebugServer(deps.Cfg.DebugAddress),
+		gateway: gw,
+	}
+}
+
+// newDebugServer возвращает служебный сервер с метриками или nil, если
+// адрес не задан.
+func newDebugServer(address string) *http.Server {
+	if address == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return &http.Server{
+		Handler:           mux,
+		Addr:              address,
+		ReadHeaderTimeout: 10 * time.Second,
 	}
 }
 
@@ -66,22 +100,49 @@ func (s *HTTPServer) MustStart() {
 	}
 }
 
-// Start слушает входящие HTTP-подключения.
+// Start слушает входящие HTTP-подключения. Служебный сервер запускается в
+// отдельной горутине; его ошибка не останавливает основной.
 func (s *HTTPServer) Start() error {
 	s.log.Info("HTTP server starting",
 		slog.String("address", s.server.Addr),
 	)
+	if s.debug != nil {
+		go s.startDebug()
+	}
 	if err := s.server.ListenAndServe(); err != nil {
 		return fmt.Errorf("http listen and serve: %w", err)
 	}
 	return nil
 }
 
-// Stop корректно завершает работу HTTP-сервера.
+func (s *HTTPServer) startDebug() {
+	s.log.Info("Debug HTTP server starting",
+		slog.String("address", s.debug.Addr),
+	)
+	if err := s.debug.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.log.Error("Debug HTTP server failed",
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// Stop корректно завершает работу HTTP-сервера. WebSocket-соединения не
+// отслеживаются http.Server, поэтому сначала шлюз закрывает их сам и ждёт
+// завершения обработчиков, а затем останавливается приём подключений.
 func (s *HTTPServer) Stop(ctx context.Context) error {
 	defer s.log.Info("HTTP server stopping")
+
+	var errs []error
+	if err := s.gateway.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("drain websocket gateway: %w", err))
+	}
 	if err := s.server.Shutdown(ctx); err != nil {
-		return fmt.Errorf("http server shutdown: %w", err)
+		errs = append(errs, fmt.Errorf("http server shutdown: %w", err))
 	}
-	return nil
+	if s.debug != nil {
+		if err := s.debug.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("debug http server shutdown: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,17 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrVerifierUnavailable означает, что токен не удалось проверить из-за
+// недоступности верификатора. В отличие от отклонённого токена, запрос
+// можно повторить позже.
+var ErrVerifierUnavailable = errors.New("identity verifier is unavailable")
+
+// Identity описывает пользователя, подтверждённого верификатором токенов.
+type Identity struct {
+	Subject   string
+	ExpiresAt time.Time
+}
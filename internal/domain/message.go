@@ -2,11 +2,17 @@
 package domain
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ErrUnknownMessage означает квитанцию на сообщение, которое не
+// отправлялось получателю квитанции от указанного отправителя или уже
+// забыто.
+var ErrUnknownMessage = errors.New("message is unknown to the recipient")
+
 // Message описывает минимальное доменное сообщение чата.
 type Message struct {
 	ID        uuid.UUID
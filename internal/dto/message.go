@@ -6,9 +6,79 @@ import (
 )
 
 // MessageCreatedEvent используется при получении сообщения от клиента.
+// From заполняется сервером по аутентифицированной сессии и не может быть
+// задано клиентом произвольно. ClientReqID — ключ идемпотентности: повторная
+// отправка с тем же значением не публикует сообщение заново. SenderSession
+// заполняется сервером и попадает только в метаданные события.
 type MessageCreatedEvent struct {
-	From    uuid.UUID `json:"with"`
-	To      uuid.UUID `json:"to"`
-	Content string    `json:"content"`
-	// ClientReqID
+	From          uuid.UUID `json:"with"`
+	To            uuid.UUID `json:"to"`
+	Content       string    `json:"content"`
+	ClientReqID   string    `json:"client_req_id,omitempty"`
+	SenderSession uuid.UUID `json:"-"`
+}
+
+// SendStatus описывает итог обработки отправленного клиентом сообщения.
+type SendStatus string
+
+const (
+	// SendStatusPublished — сообщение опубликовано в шину.
+	SendStatusPublished SendStatus = "published"
+	// SendStatusAccepted — сообщение передано асинхронному продюсеру, но
+	// брокер ещё не подтвердил запись. Если она не удастся, сообщение
+	// будет опубликовано позже из локальной очереди.
+	SendStatusAccepted SendStatus = "accepted"
+	// SendStatusFailed — публикация сообщения не удалась.
+	SendStatusFailed SendStatus = "failed"
+	// SendStatusDuplicate — сообщение с тем же ClientReqID уже было принято,
+	// возвращается идентификатор исходного сообщения.
+	SendStatusDuplicate SendStatus = "duplicate"
+	// SendStatusQueued — брокер недоступен, сообщение сохранено в локальной
+	// очереди и будет опубликовано позже.
+	SendStatusQueued SendStatus = "queued"
+	// SendStatusIgnored — квитанция относится к сообщению, участники
+	// которого уже забыты, и не публикуется.
+	SendStatusIgnored SendStatus = "ignored"
+	// SendStatusInProgress — сообщение с тем же ClientReqID ещё
+	// публикуется; итог придёт в ответ на исходный запрос, а если он
+	// потерян — повтор нужно отправить позже.
+	SendStatusInProgress SendStatus = "in_progress"
+)
+
+// MessageSendResult возвращается usecase'ом отправки: идентификатор,
+// присвоенный сообщению сервером, и статус публикации.
+type MessageSendResult struct {
+	MessageID uuid.UUID
+	Status    SendStatus
+}
+
+// ReceiptKind описывает вид квитанции о сообщении.
+type ReceiptKind string
+
+const (
+	// ReceiptReceived — сообщение дошло до клиента получателя.
+	ReceiptReceived ReceiptKind = "received"
+	// ReceiptRead — получатель прочитал сообщения вплоть до MessageID.
+	ReceiptRead ReceiptKind = "read"
+)
+
+// ReceiptEvent — квитанция получателя о сообщении отправителя Sender.
+// Recipient и RecipientSession заполняются сервером по аутентифицированной
+// сессии; RecipientSession попадает только в метаданные события.
+type ReceiptEvent struct {
+	Kind             ReceiptKind `json:"kind"`
+	MessageID        uuid.UUID   `json:"message_id"`
+	Sender           uuid.UUID   `json:"sender"`
+	Recipient        uuid.UUID   `json:"recipient"`
+	At               int64       `json:"at"`
+	RecipientSession uuid.UUID   `json:"-"`
+}
+
+// ReceiptState — состояние квитанций по сообщениям отправителя в диалоге с
+// With: последнее полученное и последнее прочитанное сообщение. Пустое
+// поле означает, что квитанций ещё не было.
+type ReceiptState struct {
+	With         uuid.UUID `json:"with"`
+	ReceivedUpTo string    `json:"received_up_to,omitempty"`
+	ReadUpTo     string    `json:"read_up_to,omitempty"`
 }
@@ -1,6 +1,26 @@
 // Package events описывает транспортные сообщения, которыми обмениваются слои приложения.
 package events
 
+import "errors"
+
+// ErrUndeliverable означает, что брокер никогда не примет сообщение,
+// например из-за его размера или неизвестного топика. Повторять публикацию
+// такого сообщения бесполезно.
+var ErrUndeliverable = errors.New("events: message can never be published")
+
+// Логические имена топиков событийной шины. Адаптер сопоставляет их с
+// реальными топиками по конфигурации.
+const (
+	// TopicMessages — сообщения чата.
+	TopicMessages = "messages"
+	// TopicReceipts — квитанции о получении и прочтении сообщений.
+	TopicReceipts = "receipts"
+	// TopicPresence — события присутствия пользователей.
+	TopicPresence = "presence"
+	// TopicNotifications — служебные уведомления пользователям.
+	TopicNotifications = "notifications"
+)
+
 // Message представляет универсальный транспортный формат сообщения для событийной шины.
 type Message struct {
 	Topic   string
@@ -8,3 +28,10 @@ type Message struct {
 	Value   []byte
 	Headers map[string][]byte
 }
+
+// StreamEntry — запись потока событий с идентификатором, который назначило
+// хранилище. Идентификаторы монотонно возрастают в пределах потока.
+type StreamEntry struct {
+	ID   string
+	Data []byte
+}
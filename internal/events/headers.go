@@ -0,0 +1,140 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Заголовки метаданных события. Потребители, в том числе сторонние сервисы,
+// маршрутизируют и сопоставляют события по ним, не разбирая тело.
+const (
+	// HeaderEventType — тип события, например EventMessageCreated.
+	HeaderEventType = "event-type"
+	// HeaderSchemaVersion — версия схемы тела события.
+	HeaderSchemaVersion = "schema-version"
+	// HeaderSenderSession — идентификатор WebSocket-сессии отправителя.
+	HeaderSenderSession = "sender-session"
+	// HeaderOriginNode — идентификатор узла шлюза, опубликовавшего событие.
+	HeaderOriginNode = "origin-node"
+	// HeaderTraceParent — контекст трассировки в формате W3C traceparent.
+	HeaderTraceParent = "traceparent"
+	// HeaderClientReqID — идентификатор запроса клиента, под которым
+	// отправлено сообщение.
+	HeaderClientReqID = "client-req-id"
+)
+
+const (
+	// EventMessageCreated — клиент отправил сообщение чата.
+	EventMessageCreated = "message.created"
+	// EventMessageReceived — получатель подтвердил получение сообщения.
+	EventMessageReceived = "message.received"
+	// EventMessageRead — получатель прочитал сообщения вплоть до указанного.
+	EventMessageRead = "message.read"
+	// SchemaVersion — текущая версия схемы событий шлюза.
+	SchemaVersion = "1"
+)
+
+// Metadata — метаданные события, передаваемые в заголовках.
+type Metadata struct {
+	EventType     string
+	SchemaVersion string
+	SenderSession string
+	OriginNode    string
+	TraceParent   string
+	ClientReqID   string
+}
+
+// Headers возвращает заголовки для непустых полей метаданных.
+func (m Metadata) Headers() map[string][]byte {
+	headers := make(map[string][]byte, 6)
+	for key, value := range map[string]string{
+		HeaderEventType:     m.EventType,
+		HeaderSchemaVersion: m.SchemaVersion,
+		HeaderSenderSession: m.SenderSession,
+		HeaderOriginNode:    m.OriginNode,
+		HeaderTraceParent:   m.TraceParent,
+		HeaderClientReqID:   m.ClientReqID,
+	} {
+		if value != "" {
+			headers[key] = []byte(value)
+		}
+	}
+	return headers
+}
+
+// MetadataFromHeaders извлекает метаданные из заголовков сообщения.
+// Отсутствующие заголовки дают пустые поля.
+func MetadataFromHeaders(headers map[string][]byte) Metadata {
+	return Metadata{
+		EventType:     string(headers[HeaderEventType]),
+		SchemaVersion: string(headers[HeaderSchemaVersion]),
+		SenderSession: string(headers[HeaderSenderSession]),
+		OriginNode:    string(headers[HeaderOriginNode]),
+		TraceParent:   string(headers[HeaderTraceParent]),
+		ClientReqID:   string(headers[HeaderClientReqID]),
+	}
+}
+
+type traceParentKey struct{}
+
+// WithTraceParent возвращает контекст с контекстом трассировки traceparent.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParent возвращает контекст трассировки из ctx или пустую строку.
+func TraceParent(ctx context.Context) string {
+	traceParent, _ := ctx.Value(traceParentKey{}).(string)
+	return traceParent
+}
+
+// NewTraceParent возвращает traceparent нового спана. Если parent —
+// корректный traceparent, трасса продолжается с его trace-id и флагами
+// (в том числе решением о сэмплировании), иначе начинается новая
+// сэмплируемая трасса.
+//
+// Пример:
+//
+//	events.NewTraceParent("") // "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func NewTraceParent(parent string) string {
+	traceID, flags := parseTraceParent(parent)
+	if traceID == "" {
+		traceID, flags = randomHex(16), "01"
+	}
+	return "00-" + traceID + "-" + randomHex(8) + "-" + flags
+}
+
+// parseTraceParent возвращает trace-id и флаги из traceparent версии 00
+// или пустые строки, если значение некорректно.
+func parseTraceParent(traceParent string) (string, string) {
+	parts := strings.Split(traceParent, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", ""
+	}
+	if !isHex(parts[1]) || parts[1] == strings.Repeat("0", 32) || !isHex(parts[3]) {
+		return "", ""
+	}
+	return parts[1], parts[3]
+}
+
+// isHex сообщает, состоит ли s из шестнадцатеричных цифр в нижнем
+// регистре, как требует W3C Trace Context.
+func isHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,47 @@
+package events
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTraceParent(t *testing.T) {
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	tests := []struct {
+		name        string
+		parent      string
+		wantTraceID string
+		wantFlags   string
+	}{
+		{name: "sampled parent", parent: "00-" + traceID + "-00f067aa0ba902b7-01", wantTraceID: traceID, wantFlags: "01"},
+		{name: "unsampled parent", parent: "00-" + traceID + "-00f067aa0ba902b7-00", wantTraceID: traceID, wantFlags: "00"},
+		{name: "no parent", wantFlags: "01"},
+		{name: "zero trace id", parent: "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-00", wantFlags: "01"},
+		{name: "invalid flags", parent: "00-" + traceID + "-00f067aa0ba902b7-zz", wantFlags: "01"},
+		{name: "unknown version", parent: "01-" + traceID + "-00f067aa0ba902b7-00", wantFlags: "01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewTraceParent(tt.parent)
+
+			parts := strings.Split(got, "-")
+			if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+				t.Fatalf("NewTraceParent(%q) = %q, want a version 00 traceparent", tt.parent, got)
+			}
+			if tt.wantTraceID != "" && parts[1] != tt.wantTraceID {
+				t.Fatalf("trace id = %s, want %s", parts[1], tt.wantTraceID)
+			}
+			if tt.wantTraceID == "" && strings.Contains(tt.parent, parts[1]) {
+				t.Fatalf("trace id %s continues an invalid parent", parts[1])
+			}
+			if strings.Contains(tt.parent, parts[2]) {
+				t.Fatalf("span id %s is not new", parts[2])
+			}
+			if parts[3] != tt.wantFlags {
+				t.Fatalf("flags = %s, want %s", parts[3], tt.wantFlags)
+			}
+		})
+	}
+}
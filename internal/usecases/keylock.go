@@ -0,0 +1,41 @@
+package usecases
+
+import "sync"
+
+// keyLocks сериализует операции с одинаковым ключом. Блокировки создаются
+// по требованию и удаляются, когда их никто не держит и не ждёт.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock захватывает блокировку ключа key и возвращает функцию её снятия.
+func (l *keyLocks) lock(key string) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*keyLock)
+	}
+	entry, ok := l.locks[key]
+	if !ok {
+		entry = &keyLock{}
+		l.locks[key] = entry
+	}
+	entry.refs++
+	l.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+
+		l.mu.Lock()
+		if entry.refs--; entry.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -6,58 +6,436 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/DENFNC/devPractice/internal/domain"
 	"github.com/DENFNC/devPractice/internal/dto"
 	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/google/uuid"
 )
 
-const deliveredMessageType = "message_delivered"
+const (
+	deliveredMessageType = "message_delivered"
 
-// Eventbus описывает шину, через которую публикуются сообщения.
+	// idempotencyPrefix — префикс ключа "idempotency:<sender>:<client_req_id>",
+	// значением которого служит идентификатор исходного сообщения.
+	idempotencyPrefix = "idempotency:"
+	// idempotencyPending — префикс значения ключа идемпотентности, пока
+	// исходное сообщение публикуется.
+	idempotencyPending = "pending:"
+	// idempotencyPendingTTL ограничивает жизнь незавершённого резерва, чтобы
+	// после падения узла во время публикации клиент мог повторить отправку.
+	idempotencyPendingTTL = time.Minute
+	// deliveredPrefix — префикс метки "delivered:<message_id>", которой
+	// отмечаются уже доставленные получателю сообщения.
+	deliveredPrefix = "delivered:"
+	// messagePrefix — префикс хеша "message:<message_id>" с участниками
+	// сообщения, по которому проверяются квитанции.
+	messagePrefix = "message:"
+
+	messageFieldSender    = "sender"
+	messageFieldRecipient = "recipient"
+	// publishedTimeout ограничивает обработку результата асинхронной
+	// публикации, у которой нет контекста запроса.
+	publishedTimeout = 5 * time.Second
+)
+
+// Eventbus описывает шину, через которую публикуются сообщения. Сообщения
+// с одинаковым Key должны сохранять порядок доставки. Асинхронная шина
+// сообщает о сбоях записи через HandlePublished.
 type Eventbus interface {
-	WriteMessage(ctx context.Context, msg []byte) error
+	WriteMessage(ctx context.Context, msg events.Message) error
 }
 
-// Notifier уведомляет получателя через активные сессии.
+// Notifier уведомляет получателя через активные сессии. Notify возвращает
+// ошибку, только если сообщение не получила ни одна сессия. События с
+// одинаковым непустым eventKey — повторные доставки одного события.
 type Notifier interface {
-	Notify(ctx context.Context, userID string, messageType string, payload any) error
+	Notify(ctx context.Context, userID, eventKey, messageType string, payload any) error
+}
+
+// IdempotencyStore хранит ключи идемпотентности отправки. AddIfAbsent должен
+// быть атомарным, например SET NX в Redis.
+type IdempotencyStore interface {
+	Add(ctx context.Context, key string, value any, expiration time.Duration) error
+	AddIfAbsent(ctx context.Context, key string, value any, expiration time.Duration) (bool, error)
+	Get(ctx context.Context, key string) (string, error)
+	Remove(ctx context.Context, keys ...string) error
+}
+
+// MessageStore хранит участников отправленных сообщений.
+type MessageStore interface {
+	HashSet(ctx context.Context, key string, fields map[string]string, expiration time.Duration) error
+}
+
+// DedupeStore хранит метки доставленных сообщений. AddIfAbsent должен быть
+// атомарным, например SET NX в Redis: по нему ровно один потребитель
+// захватывает доставку сообщения.
+type DedupeStore interface {
+	AddIfAbsent(ctx context.Context, key string, value any, expiration time.Duration) (bool, error)
+	Remove(ctx context.Context, keys ...string) error
+}
+
+// Outbox — локальная очередь событий, которые не удалось опубликовать.
+// Append должен сохранять событие надёжно; Pending сообщает, есть ли в
+// очереди события с ключом key.
+type Outbox interface {
+	Append(ctx context.Context, msg events.Message) error
+	Pending(key []byte) bool
 }
 
 // MessageUsecase инкапсулирует бизнес-логику отправки сообщений.
 type MessageUsecase struct {
-	eventbus Eventbus
-	notifier Notifier
+	eventbus       Eventbus
+	notifier       Notifier
+	idempotency    IdempotencyStore
+	idempotencyTTL time.Duration
+	dedupe         DedupeStore
+	dedupeTTL      time.Duration
+	nodeID         string
+	outbox         Outbox
+	messages       MessageStore
+	messageTTL     time.Duration
+	asyncPublish   bool
+	log            *slog.Logger
+	conversations  keyLocks
+}
+
+// MessageUsecaseDeps агрегирует зависимости usecase'а. Idempotency
+// необязателен: без него повторные отправки публикуются заново. Dedupe
+// также необязателен: без него повторные доставки Kafka доходят до
+// получателя несколько раз. NodeID попадает в заголовок origin-node
+// публикуемых событий. Outbox необязателен: без него сообщение, которое не
+// удалось опубликовать, отклоняется. Messages необязателен: в нём на
+// MessageTTL сохраняются участники сообщения, без которых получатель не
+// может подтвердить его квитанцией. AsyncPublish сообщает, что Eventbus
+// возвращается из WriteMessage до ответа брокера: тогда клиент получает
+// статус accepted вместо published.
+type MessageUsecaseDeps struct {
+	Eventbus       Eventbus
+	Notifier       Notifier
+	Idempotency    IdempotencyStore
+	IdempotencyTTL time.Duration
+	Dedupe         DedupeStore
+	DedupeTTL      time.Duration
+	NodeID         string
+	Outbox         Outbox
+	Messages       MessageStore
+	MessageTTL     time.Duration
+	AsyncPublish   bool
+	Log            *slog.Logger
 }
 
 // NewMessageUsecase конструирует usecase с необходимыми зависимостями.
-func NewMessageUsecase(bus Eventbus, notifier Notifier) *MessageUsecase {
+func NewMessageUsecase(deps *MessageUsecaseDeps) *MessageUsecase {
+	if deps == nil || deps.Eventbus == nil {
+		panic("eventbus cannot be nil")
+	}
+	if deps.Log == nil {
+		panic("logger cannot be nil")
+	}
+
 	return &MessageUsecase{
-		eventbus: bus,
-		notifier: notifier,
+		eventbus:       deps.Eventbus,
+		notifier:       deps.Notifier,
+		idempotency:    deps.Idempotency,
+		idempotencyTTL: deps.IdempotencyTTL,
+		dedupe:         deps.Dedupe,
+		dedupeTTL:      deps.DedupeTTL,
+		nodeID:         deps.NodeID,
+		outbox:         deps.Outbox,
+		messages:       deps.Messages,
+		messageTTL:     deps.MessageTTL,
+		asyncPublish:   deps.AsyncPublish,
+		log:            deps.Log,
 	}
 }
 
 // SendMessage валидирует DTO, конструирует доменную модель и публикует её в шину.
-func (uc *MessageUsecase) SendMessage(ctx context.Context, dto *dto.MessageCreatedEvent) error {
-	if dto == nil {
-		return errors.New("message dto is nil")
+// Результат содержит идентификатор сообщения и статус публикации; при сбое
+// публикации он возвращается вместе с ошибкой. Если шина асинхронная,
+// успешная запись означает только передачу продюсеру, и возвращается
+// статус accepted.
+//
+// До публикации участники сообщения сохраняются в Messages для проверки
+// квитанций; сбой записи только логируется.
+//
+// Если задан ClientReqID, пара (отправитель, ClientReqID) резервируется в
+// хранилище идемпотентности до публикации и подтверждается после неё.
+// Повторная отправка возвращает идентификатор исходного сообщения со
+// статусом duplicate, не публикуя его снова, а пока исходное сообщение
+// публикуется — со статусом in_progress. При сбое публикации резерв
+// снимается, чтобы клиент мог повторить запрос.
+//
+// Событие публикуется с заголовками метаданных (см. events.Metadata):
+// тип и версия схемы, сессия отправителя, узел шлюза и traceparent,
+// продолжающий трассу из ctx.
+//
+// Если задан Outbox, событие, которое не удалось опубликовать, сохраняется
+// в нём и возвращается статус queued; резерв идемпотентности при этом
+// остаётся. Пока в очереди есть события того же диалога, новые события
+// тоже ставятся в очередь, чтобы не обогнать их. Проверка очереди,
+// публикация и постановка в очередь выполняются под блокировкой диалога,
+// иначе параллельная отправка могла бы проскочить между ними. Сбой
+// асинхронной записи, обнаруженный после ответа клиенту, обрабатывает
+// HandlePublished; порядок таких сообщений не сохраняется.
+func (uc *MessageUsecase) SendMessage(ctx context.Context, in *dto.MessageCreatedEvent) (*dto.MessageSendResult, error) {
+	if in == nil {
+		return nil, errors.New("message dto is nil")
+	}
+	if in.To == uuid.Nil {
+		return nil, errors.New("message recipient is empty")
+	}
+
+	message := domain.NewMessage(in.From.String(), in.To.String(), in.Content)
+	result := &dto.MessageSendResult{MessageID: message.ID, Status: dto.SendStatusPublished}
+
+	key := uc.idempotencyKey(in)
+	if key != "" {
+		original, pending, err := uc.reserve(ctx, key, message.ID)
+		if err != nil {
+			result.Status = dto.SendStatusFailed
+			return result, err
+		}
+		if original != uuid.Nil {
+			status := dto.SendStatusDuplicate
+			if pending {
+				status = dto.SendStatusInProgress
+			}
+			return &dto.MessageSendResult{MessageID: original, Status: status}, nil
+		}
 	}
 
-	message := domain.NewMessage(dto.From.String(), dto.To.String(), dto.Content)
 	payload, err := json.Marshal(message)
 	if err != nil {
-		return fmt.Errorf("marshal message: %w", err)
+		return nil, errors.Join(fmt.Errorf("marshal message: %w", err), uc.release(ctx, key))
+	}
+	uc.record(ctx, message)
+
+	event := events.Message{
+		Topic:   events.TopicMessages,
+		Key:     []byte(conversationKey(message.With, message.To)),
+		Value:   payload,
+		Headers: uc.metadata(ctx, in).Headers(),
+	}
+	if uc.outbox != nil {
+		unlock := uc.conversations.lock(string(event.Key))
+		defer unlock()
+
+		if uc.outbox.Pending(event.Key) {
+			return uc.enqueue(ctx, event, result, key, nil)
+		}
+	}
+	if err := uc.eventbus.WriteMessage(ctx, event); err != nil {
+		if uc.outbox != nil {
+			return uc.enqueue(ctx, event, result, key, err)
+		}
+		result.Status = dto.SendStatusFailed
+		return result, errors.Join(fmt.Errorf("publish message: %w", err), uc.release(ctx, key))
+	}
+
+	uc.confirm(ctx, key, message.ID)
+	if uc.asyncPublish {
+		result.Status = dto.SendStatusAccepted
+	}
+	return result, nil
+}
+
+// enqueue сохраняет событие в Outbox. publishErr — ошибка публикации,
+// из-за которой событие ставится в очередь; она возвращается, только если
+// не удалось и сохранение.
+func (uc *MessageUsecase) enqueue(
+	ctx context.Context,
+	event events.Message,
+	result *dto.MessageSendResult,
+	key string,
+	publishErr error,
+) (*dto.MessageSendResult, error) {
+	if err := uc.outbox.Append(ctx, event); err != nil {
+		result.Status = dto.SendStatusFailed
+		return result, errors.Join(
+			publishErr,
+			fmt.Errorf("append message to outbox: %w", err),
+			uc.release(ctx, key),
+		)
 	}
+	uc.confirm(ctx, key, result.MessageID)
+	result.Status = dto.SendStatusQueued
+	return result, nil
+}
 
-	if err := uc.eventbus.WriteMessage(ctx, payload); err != nil {
-		return fmt.Errorf("publish message: %w", err)
+// record сохраняет участников сообщения до его публикации, чтобы квитанцию
+// получателя можно было проверить, как только сообщение до него дойдёт.
+// Сбой не мешает отправке: без записи отклоняются только квитанции на это
+// сообщение.
+func (uc *MessageUsecase) record(ctx context.Context, message *domain.Message) {
+	if uc.messages == nil {
+		return
+	}
+	fields := map[string]string{
+		messageFieldSender:    message.With,
+		messageFieldRecipient: message.To,
 	}
+	if err := uc.messages.HashSet(ctx, messagePrefix+message.ID.String(), fields, uc.messageTTL); err != nil {
+		uc.log.Warn("failed to record message participants",
+			slog.String("message_id", message.ID.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+}
 
+// metadata собирает метаданные события отправки сообщения.
+func (uc *MessageUsecase) metadata(ctx context.Context, in *dto.MessageCreatedEvent) events.Metadata {
+	meta := events.Metadata{
+		EventType:     events.EventMessageCreated,
+		SchemaVersion: events.SchemaVersion,
+		OriginNode:    uc.nodeID,
+		TraceParent:   events.NewTraceParent(events.TraceParent(ctx)),
+		ClientReqID:   in.ClientReqID,
+	}
+	if in.SenderSession != uuid.Nil {
+		meta.SenderSession = in.SenderSession.String()
+	}
+	return meta
+}
+
+// reserve записывает под ключом идемпотентности незавершённый резерв
+// messageID. Если ключ уже занят, возвращает идентификатор исходного
+// сообщения и признак того, что его публикация ещё не завершена.
+func (uc *MessageUsecase) reserve(ctx context.Context, key string, messageID uuid.UUID) (uuid.UUID, bool, error) {
+	ttl := idempotencyPendingTTL
+	if uc.idempotencyTTL > 0 && uc.idempotencyTTL < ttl {
+		ttl = uc.idempotencyTTL
+	}
+	added, err := uc.idempotency.AddIfAbsent(ctx, key, idempotencyPending+messageID.String(), ttl)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("reserve idempotency key: %w", err)
+	}
+	if added {
+		return uuid.Nil, false, nil
+	}
+
+	value, err := uc.idempotency.Get(ctx, key)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("get idempotency key: %w", err)
+	}
+	value, pending := strings.CutPrefix(value, idempotencyPending)
+	original, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("parse idempotency key value: %w", err)
+	}
+	return original, pending, nil
+}
+
+// confirm отмечает резерв завершённым и продлевает его на полный TTL.
+// Сообщение к этому моменту уже принято, поэтому сбой только логируется:
+// до истечения резерва повторы получат in_progress.
+func (uc *MessageUsecase) confirm(ctx context.Context, key string, messageID uuid.UUID) {
+	if key == "" {
+		return
+	}
+	if err := uc.idempotency.Add(ctx, key, messageID.String(), uc.idempotencyTTL); err != nil {
+		uc.log.Warn("failed to confirm idempotency key",
+			slog.String("message_id", messageID.String()),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func (uc *MessageUsecase) release(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	if err := uc.idempotency.Remove(ctx, key); err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
 	return nil
 }
 
+func (uc *MessageUsecase) idempotencyKey(in *dto.MessageCreatedEvent) string {
+	return uc.idempotencyKeyFor(in.From.String(), in.ClientReqID)
+}
+
+func (uc *MessageUsecase) idempotencyKeyFor(sender, clientReqID string) string {
+	if uc.idempotency == nil || clientReqID == "" {
+		return ""
+	}
+	return idempotencyPrefix + sender + ":" + clientReqID
+}
+
+// HandlePublished получает результат асинхронной публикации. Клиент к
+// этому моменту уже получил ответ, поэтому события, которые не удалось
+// записать, сохраняются в Outbox и будут опубликованы ретранслятором. Если
+// Outbox не задан или сохранить не удалось, с сообщения снимается резерв
+// идемпотентности, чтобы повтор клиента опубликовал его заново.
+//
+// Порядок диалога при этом не гарантируется: пока пакет ждал записи,
+// следующие сообщения того же диалога могли уйти в Kafka напрямую, и
+// ретранслятор опубликует сохранённое сообщение уже после них. Outbox
+// удерживает порядок только для сообщений, отправленных после сбоя.
+func (uc *MessageUsecase) HandlePublished(messages []events.Message, err error) {
+	if err == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishedTimeout)
+	defer cancel()
+
+	for _, msg := range messages {
+		if uc.outbox != nil {
+			appendErr := uc.outbox.Append(ctx, msg)
+			if appendErr == nil {
+				continue
+			}
+			uc.log.Error("failed to append unpublished event to outbox",
+				slog.String("topic", msg.Topic),
+				slog.String("error", appendErr.Error()),
+			)
+		}
+		if releaseErr := uc.release(ctx, uc.publishedKey(msg)); releaseErr != nil {
+			uc.log.Warn("failed to release idempotency key of unpublished message",
+				slog.String("error", releaseErr.Error()),
+			)
+		}
+	}
+}
+
+// publishedKey восстанавливает ключ идемпотентности опубликованного
+// сообщения по его заголовкам и телу.
+func (uc *MessageUsecase) publishedKey(msg events.Message) string {
+	meta := events.MetadataFromHeaders(msg.Headers)
+	if meta.EventType != events.EventMessageCreated || meta.ClientReqID == "" {
+		return ""
+	}
+
+	var message domain.Message
+	if err := json.Unmarshal(msg.Value, &message); err != nil {
+		return ""
+	}
+	return uc.idempotencyKeyFor(message.With, meta.ClientReqID)
+}
+
+// conversationKey возвращает ключ партиционирования диалога. Пара
+// участников упорядочивается, чтобы сообщения в обе стороны попадали в одну
+// партицию и доставлялись в порядке отправки.
+func conversationKey(from, to string) string {
+	if from > to {
+		from, to = to, from
+	}
+	return from + ":" + to
+}
+
 // HandleDelivery вызывается после подтверждения Kafka и отправляет сообщение получателю.
+// Повторные доставки того же сообщения отсекаются меткой по его
+// идентификатору: до Notify метка атомарно захватывается, поэтому
+// параллельные доставки одного сообщения, например во время ребалансировки,
+// не отправят его в живые сессии дважды. Если Notify не удался, метка
+// снимается, и повторная доставка из Kafka пройдёт; поток событий
+// получателя при этом не запишет сообщение второй раз. Частичный сбой
+// считается доставкой: сессии, которые уже получили сообщение, не должны
+// получить его повторно.
 func (uc *MessageUsecase) HandleDelivery(ctx context.Context, event events.Message) error {
 	if uc.notifier == nil {
 		return errors.New("notifier is not configured")
@@ -72,9 +450,50 @@ func (uc *MessageUsecase) HandleDelivery(ctx context.Context, event events.Messa
 		return errors.New("delivered message recipient is empty")
 	}
 
-	if err := uc.notifier.Notify(ctx, message.To, deliveredMessageType, message); err != nil {
-		return fmt.Errorf("notify recipient: %w", err)
+	claimed, err := uc.claimDelivery(ctx, message.ID)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+
+	// Повторная доставка того же сообщения не должна попасть в поток
+	// событий получателя второй раз.
+	eventKey := ""
+	if message.ID != uuid.Nil {
+		eventKey = deliveredMessageType + ":" + message.ID.String()
+	}
+	if err := uc.notifier.Notify(ctx, message.To, eventKey, deliveredMessageType, message); err != nil {
+		return errors.Join(fmt.Errorf("notify recipient: %w", err), uc.releaseDelivery(ctx, message.ID))
+	}
+	return nil
+}
+
+// claimDelivery атомарно ставит метку доставки сообщения и сообщает,
+// захвачена ли доставка этим вызовом. Без DedupeStore или идентификатора
+// сообщения доставка всегда разрешена.
+func (uc *MessageUsecase) claimDelivery(ctx context.Context, messageID uuid.UUID) (bool, error) {
+	if uc.dedupe == nil || messageID == uuid.Nil {
+		return true, nil
 	}
 
+	claimed, err := uc.dedupe.AddIfAbsent(ctx, deliveredPrefix+messageID.String(), time.Now().Unix(), uc.dedupeTTL)
+	if err != nil {
+		return false, fmt.Errorf("claim message %s delivery: %w", messageID, err)
+	}
+	return claimed, nil
+}
+
+// releaseDelivery снимает метку доставки, чтобы повторная доставка из
+// Kafka снова отправила сообщение.
+func (uc *MessageUsecase) releaseDelivery(ctx context.Context, messageID uuid.UUID) error {
+	if uc.dedupe == nil || messageID == uuid.Nil {
+		return nil
+	}
+
+	if err := uc.dedupe.Remove(ctx, deliveredPrefix+messageID.String()); err != nil {
+		return fmt.Errorf("release message %s delivery: %w", messageID, err)
+	}
 	return nil
 }
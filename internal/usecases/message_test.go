@@ -0,0 +1,342 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/DENFNC/devPractice/internal/dto"
+	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/google/uuid"
+)
+
+// memoryKV — key-value хранилище в памяти для хранилищ идемпотентности,
+// меток доставки и участников сообщений. Сроки жизни не учитываются.
+type memoryKV struct {
+	mu     sync.Mutex
+	values map[string]string
+	hashes map[string]map[string]string
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{
+		values: make(map[string]string),
+		hashes: make(map[string]map[string]string),
+	}
+}
+
+func (m *memoryKV) Add(_ context.Context, key string, value any, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[key] = fmt.Sprint(value)
+	return nil
+}
+
+func (m *memoryKV) AddIfAbsent(_ context.Context, key string, value any, _ time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.values[key]; ok {
+		return false, nil
+	}
+	m.values[key] = fmt.Sprint(value)
+	return true, nil
+}
+
+func (m *memoryKV) Get(_ context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found", key)
+	}
+	return value, nil
+}
+
+func (m *memoryKV) Remove(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.values, key)
+	}
+	return nil
+}
+
+func (m *memoryKV) HashSet(_ context.Context, key string, fields map[string]string, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hash := m.hashes[key]
+	if hash == nil {
+		hash = make(map[string]string)
+		m.hashes[key] = hash
+	}
+	for field, value := range fields {
+		hash[field] = value
+	}
+	return nil
+}
+
+func (m *memoryKV) value(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// recordingEventbus запоминает опубликованные события и возвращает err.
+type recordingEventbus struct {
+	mu        sync.Mutex
+	published []events.Message
+	err       error
+}
+
+func (b *recordingEventbus) WriteMessage(_ context.Context, msg events.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	b.published = append(b.published, msg)
+	return nil
+}
+
+// countingNotifier считает вызовы Notify. delay удерживает вызов, чтобы
+// параллельные доставки пересеклись; err возвращается первым failures
+// вызовам.
+type countingNotifier struct {
+	mu       sync.Mutex
+	calls    int
+	delay    time.Duration
+	err      error
+	failures int
+}
+
+func (n *countingNotifier) Notify(context.Context, string, string, string, any) error {
+	time.Sleep(n.delay)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls++
+	if n.failures > 0 {
+		n.failures--
+		return n.err
+	}
+	return nil
+}
+
+func (n *countingNotifier) count() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.calls
+}
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func deliveredEvent(t *testing.T) events.Message {
+	t.Helper()
+
+	data, err := json.Marshal(domain.NewMessage("sender", "recipient", "hi"))
+	if err != nil {
+		t.Fatalf("marshal message: %v", err)
+	}
+	return events.Message{Topic: events.TopicMessages, Value: data}
+}
+
+func TestHandleDeliveryConcurrentRedelivery(t *testing.T) {
+	notifier := &countingNotifier{delay: 20 * time.Millisecond}
+	uc := NewMessageUsecase(&MessageUsecaseDeps{
+		Eventbus:  &recordingEventbus{},
+		Notifier:  notifier,
+		Dedupe:    newMemoryKV(),
+		DedupeTTL: time.Hour,
+		Log:       testLogger(),
+	})
+	event := deliveredEvent(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- uc.HandleDelivery(context.Background(), event)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("HandleDelivery: %v", err)
+		}
+	}
+	if got := notifier.count(); got != 1 {
+		t.Fatalf("notify calls = %d, want 1", got)
+	}
+}
+
+func TestHandleDeliveryReleasesClaimOnNotifyFailure(t *testing.T) {
+	notifier := &countingNotifier{err: errors.New("no sessions"), failures: 1}
+	uc := NewMessageUsecase(&MessageUsecaseDeps{
+		Eventbus:  &recordingEventbus{},
+		Notifier:  notifier,
+		Dedupe:    newMemoryKV(),
+		DedupeTTL: time.Hour,
+		Log:       testLogger(),
+	})
+	event := deliveredEvent(t)
+	ctx := context.Background()
+
+	if err := uc.HandleDelivery(ctx, event); err == nil {
+		t.Fatal("HandleDelivery succeeded although notify failed")
+	}
+	if err := uc.HandleDelivery(ctx, event); err != nil {
+		t.Fatalf("redelivery: %v", err)
+	}
+	if err := uc.HandleDelivery(ctx, event); err != nil {
+		t.Fatalf("duplicate delivery: %v", err)
+	}
+	if got := notifier.count(); got != 2 {
+		t.Fatalf("notify calls = %d, want 2", got)
+	}
+}
+
+func newIdempotentUsecase(bus *recordingEventbus, store *memoryKV) *MessageUsecase {
+	return NewMessageUsecase(&MessageUsecaseDeps{
+		Eventbus:       bus,
+		Idempotency:    store,
+		IdempotencyTTL: time.Hour,
+		Log:            testLogger(),
+	})
+}
+
+func sendRequest(from uuid.UUID) *dto.MessageCreatedEvent {
+	return &dto.MessageCreatedEvent{
+		From:        from,
+		To:          uuid.New(),
+		Content:     "hi",
+		ClientReqID: "req-1",
+	}
+}
+
+func TestSendMessageDuplicateAfterConfirm(t *testing.T) {
+	bus := &recordingEventbus{}
+	store := newMemoryKV()
+	uc := newIdempotentUsecase(bus, store)
+	ctx := context.Background()
+	in := sendRequest(uuid.New())
+
+	first, err := uc.SendMessage(ctx, in)
+	if err != nil {
+		t.Fatalf("first send: %v", err)
+	}
+	if first.Status != dto.SendStatusPublished {
+		t.Fatalf("first status = %s, want %s", first.Status, dto.SendStatusPublished)
+	}
+	if value, _ := store.value(uc.idempotencyKey(in)); value != first.MessageID.String() {
+		t.Fatalf("idempotency key = %q, want confirmed %s", value, first.MessageID)
+	}
+
+	second, err := uc.SendMessage(ctx, in)
+	if err != nil {
+		t.Fatalf("second send: %v", err)
+	}
+	if second.Status != dto.SendStatusDuplicate || second.MessageID != first.MessageID {
+		t.Fatalf("second = %+v, want duplicate of %s", second, first.MessageID)
+	}
+	if len(bus.published) != 1 {
+		t.Fatalf("published %d events, want 1", len(bus.published))
+	}
+}
+
+func TestSendMessageInProgressWhilePending(t *testing.T) {
+	bus := &recordingEventbus{}
+	store := newMemoryKV()
+	uc := newIdempotentUsecase(bus, store)
+	ctx := context.Background()
+	in := sendRequest(uuid.New())
+
+	original := uuid.New()
+	if err := store.Add(ctx, uc.idempotencyKey(in), idempotencyPending+original.String(), time.Minute); err != nil {
+		t.Fatalf("seed pending key: %v", err)
+	}
+
+	result, err := uc.SendMessage(ctx, in)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if result.Status != dto.SendStatusInProgress || result.MessageID != original {
+		t.Fatalf("result = %+v, want in_progress of %s", result, original)
+	}
+	if len(bus.published) != 0 {
+		t.Fatalf("published %d events while the original is pending", len(bus.published))
+	}
+}
+
+func TestSendMessageReleasesKeyOnFailedPublish(t *testing.T) {
+	bus := &recordingEventbus{err: errors.New("broker down")}
+	store := newMemoryKV()
+	uc := newIdempotentUsecase(bus, store)
+	ctx := context.Background()
+	in := sendRequest(uuid.New())
+
+	result, err := uc.SendMessage(ctx, in)
+	if err == nil {
+		t.Fatal("send succeeded although publish failed")
+	}
+	if result == nil || result.Status != dto.SendStatusFailed {
+		t.Fatalf("result = %+v, want failed", result)
+	}
+	if _, ok := store.value(uc.idempotencyKey(in)); ok {
+		t.Fatal("idempotency key kept after a failed publish")
+	}
+
+	bus.err = nil
+	retry, err := uc.SendMessage(ctx, in)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retry.Status != dto.SendStatusPublished {
+		t.Fatalf("retry status = %s, want %s", retry.Status, dto.SendStatusPublished)
+	}
+}
+
+func TestHandlePublishedReleasesKeyWithoutOutbox(t *testing.T) {
+	bus := &recordingEventbus{}
+	store := newMemoryKV()
+	uc := NewMessageUsecase(&MessageUsecaseDeps{
+		Eventbus:       bus,
+		Idempotency:    store,
+		IdempotencyTTL: time.Hour,
+		AsyncPublish:   true,
+		Log:            testLogger(),
+	})
+	ctx := context.Background()
+	in := sendRequest(uuid.New())
+
+	result, err := uc.SendMessage(ctx, in)
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if result.Status != dto.SendStatusAccepted {
+		t.Fatalf("status = %s, want %s", result.Status, dto.SendStatusAccepted)
+	}
+	key := uc.idempotencyKey(in)
+	if _, ok := store.value(key); !ok {
+		t.Fatal("idempotency key not confirmed")
+	}
+
+	uc.HandlePublished(bus.published, errors.New("write failed"))
+
+	if _, ok := store.value(key); ok {
+		t.Fatal("idempotency key kept after a failed async write")
+	}
+}
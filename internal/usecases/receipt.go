@@ -0,0 +1,243 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/DENFNC/devPractice/internal/dto"
+	"github.com/DENFNC/devPractice/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	receivedMessageType = "message_received"
+	readMessageType     = "message_read"
+
+	// receiptsPrefix — префикс хеша "receipts:<sender>:<recipient>" с
+	// состоянием квитанций по сообщениям sender в диалоге с recipient.
+	receiptsPrefix = "receipts:"
+
+	receiptFieldReceived = "received"
+	receiptFieldRead     = "read"
+)
+
+// ReceiptStore хранит состояние квитанций в хешах. HashMax должен атомарно
+// поднимать поля до переданных значений и возвращать состояние после
+// обновления, например Lua-скриптом в Redis. Через HashGetAll читаются и
+// участники сообщений, сохранённые MessageUsecase.
+type ReceiptStore interface {
+	HashMax(ctx context.Context, key string, fields map[string]string, expiration time.Duration) (map[string]string, error)
+	HashGetAll(ctx context.Context, key string) (map[string]string, error)
+}
+
+// ReceiptUsecase инкапсулирует квитанции о получении и прочтении
+// сообщений. Квитанции публикуются в шину и после подтверждения Kafka
+// сохраняются и отправляются во все сессии отправителя сообщения.
+//
+// Идентификаторы сообщений — UUIDv7, их строковый порядок совпадает с
+// порядком создания, поэтому состояние хранится как «получено/прочитано
+// вплоть до» и только растёт: повторные и запоздавшие квитанции его не
+// откатывают.
+type ReceiptUsecase struct {
+	eventbus   Eventbus
+	notifier   Notifier
+	store      ReceiptStore
+	ttl        time.Duration
+	messageTTL time.Duration
+	nodeID     string
+	async      bool
+}
+
+// ReceiptUsecaseDeps агрегирует зависимости usecase'а квитанций. TTL
+// продлевается при каждой квитанции диалога; нулевое значение хранит
+// состояние бессрочно. MessageTTL — сколько MessageUsecase хранит
+// участников сообщения: квитанции на более старые сообщения игнорируются.
+// AsyncPublish сообщает, что Eventbus возвращается из WriteMessage до
+// ответа брокера.
+type ReceiptUsecaseDeps struct {
+	Eventbus     Eventbus
+	Notifier     Notifier
+	Store        ReceiptStore
+	TTL          time.Duration
+	MessageTTL   time.Duration
+	NodeID       string
+	AsyncPublish bool
+}
+
+// NewReceiptUsecase конструирует usecase квитанций с необходимыми зависимостями.
+func NewReceiptUsecase(deps *ReceiptUsecaseDeps) *ReceiptUsecase {
+	if deps == nil || deps.Eventbus == nil {
+		panic("eventbus cannot be nil")
+	}
+	if deps.Store == nil {
+		panic("receipt store cannot be nil")
+	}
+
+	return &ReceiptUsecase{
+		eventbus:   deps.Eventbus,
+		notifier:   deps.Notifier,
+		store:      deps.Store,
+		ttl:        deps.TTL,
+		messageTTL: deps.MessageTTL,
+		nodeID:     deps.NodeID,
+		async:      deps.AsyncPublish,
+	}
+}
+
+// Acknowledge публикует квитанцию получателя в топик квитанций. Ключом
+// служит диалог, поэтому квитанции одного диалога обрабатываются по
+// порядку. Квитанция принимается, только если сообщение было отправлено
+// Sender получателю Recipient, иначе возвращается domain.ErrUnknownMessage.
+// Квитанция на сообщение старше MessageTTL, участники которого уже забыты,
+// не публикуется и возвращает статус ignored. Иначе статус — published, а
+// для асинхронной шины — accepted.
+func (uc *ReceiptUsecase) Acknowledge(ctx context.Context, in *dto.ReceiptEvent) (dto.SendStatus, error) {
+	if in == nil {
+		return dto.SendStatusFailed, errors.New("receipt dto is nil")
+	}
+	expired, err := uc.verify(ctx, in)
+	if err != nil {
+		return dto.SendStatusFailed, err
+	}
+	if expired {
+		return dto.SendStatusIgnored, nil
+	}
+	if in.At == 0 {
+		in.At = time.Now().Unix()
+	}
+
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return dto.SendStatusFailed, fmt.Errorf("marshal receipt: %w", err)
+	}
+
+	event := events.Message{
+		Topic:   events.TopicReceipts,
+		Key:     []byte(conversationKey(in.Sender.String(), in.Recipient.String())),
+		Value:   payload,
+		Headers: uc.metadata(ctx, in).Headers(),
+	}
+	if err := uc.eventbus.WriteMessage(ctx, event); err != nil {
+		return dto.SendStatusFailed, fmt.Errorf("publish receipt: %w", err)
+	}
+	if uc.async {
+		return dto.SendStatusAccepted, nil
+	}
+	return dto.SendStatusPublished, nil
+}
+
+// HandleReceipt вызывается после подтверждения Kafka: сохраняет квитанцию
+// и отправляет отправителю сообщения текущее состояние квитанций диалога.
+// Прочтение подразумевает и получение. Повторная доставка безопасна:
+// состояние не меняется, а отправитель получает его ещё раз.
+func (uc *ReceiptUsecase) HandleReceipt(ctx context.Context, event events.Message) error {
+	if uc.notifier == nil {
+		return errors.New("notifier is not configured")
+	}
+
+	var receipt dto.ReceiptEvent
+	if err := json.Unmarshal(event.Value, &receipt); err != nil {
+		return fmt.Errorf("unmarshal receipt: %w", err)
+	}
+	if receipt.Sender == uuid.Nil || receipt.Recipient == uuid.Nil {
+		return errors.New("receipt participants are empty")
+	}
+
+	fields := map[string]string{receiptFieldReceived: receipt.MessageID.String()}
+	messageType := receivedMessageType
+	switch receipt.Kind {
+	case dto.ReceiptReceived:
+	case dto.ReceiptRead:
+		fields[receiptFieldRead] = receipt.MessageID.String()
+		messageType = readMessageType
+	default:
+		return fmt.Errorf("unknown receipt kind %q", receipt.Kind)
+	}
+
+	hash, err := uc.store.HashMax(ctx, receiptKey(receipt.Sender, receipt.Recipient), fields, uc.ttl)
+	if err != nil {
+		return fmt.Errorf("store receipt: %w", err)
+	}
+
+	state := receiptState(receipt.Recipient, hash)
+	// Состояние квитанций меняется между доставками, поэтому каждое
+	// уведомление — новое событие.
+	if err := uc.notifier.Notify(ctx, receipt.Sender.String(), "", messageType, state); err != nil {
+		return fmt.Errorf("notify sender: %w", err)
+	}
+	return nil
+}
+
+// Receipts возвращает состояние квитанций по сообщениям sender в диалоге с
+// with. Клиент запрашивает его после переподключения, чтобы не зависеть от
+// уведомлений, пришедших, пока он был офлайн.
+func (uc *ReceiptUsecase) Receipts(ctx context.Context, sender, with uuid.UUID) (*dto.ReceiptState, error) {
+	hash, err := uc.store.HashGetAll(ctx, receiptKey(sender, with))
+	if err != nil {
+		return nil, fmt.Errorf("get receipts: %w", err)
+	}
+	state := receiptState(with, hash)
+	return &state, nil
+}
+
+// verify проверяет по записи участников, что сообщение адресовано
+// получателю квитанции и отправлено указанным отправителем. Если записи
+// нет, а сообщение старше MessageTTL, она истекла: verify сообщает об этом,
+// не возвращая ошибку.
+func (uc *ReceiptUsecase) verify(ctx context.Context, in *dto.ReceiptEvent) (bool, error) {
+	participants, err := uc.store.HashGetAll(ctx, messagePrefix+in.MessageID.String())
+	if err != nil {
+		return false, fmt.Errorf("get message participants: %w", err)
+	}
+	if len(participants) == 0 && uc.expired(in.MessageID) {
+		return true, nil
+	}
+	if participants[messageFieldSender] != in.Sender.String() ||
+		participants[messageFieldRecipient] != in.Recipient.String() {
+		return false, fmt.Errorf("%w: %s", domain.ErrUnknownMessage, in.MessageID)
+	}
+	return false, nil
+}
+
+// expired сообщает, истекла ли запись участников сообщения messageID по
+// времени создания из его UUIDv7.
+func (uc *ReceiptUsecase) expired(messageID uuid.UUID) bool {
+	if uc.messageTTL <= 0 || messageID.Version() != 7 {
+		return false
+	}
+	sec, nsec := messageID.Time().UnixTime()
+	return time.Since(time.Unix(sec, nsec)) > uc.messageTTL
+}
+
+// metadata собирает метаданные события квитанции.
+func (uc *ReceiptUsecase) metadata(ctx context.Context, in *dto.ReceiptEvent) events.Metadata {
+	meta := events.Metadata{
+		EventType:     events.EventMessageReceived,
+		SchemaVersion: events.SchemaVersion,
+		OriginNode:    uc.nodeID,
+		TraceParent:   events.NewTraceParent(events.TraceParent(ctx)),
+	}
+	if in.Kind == dto.ReceiptRead {
+		meta.EventType = events.EventMessageRead
+	}
+	if in.RecipientSession != uuid.Nil {
+		meta.SenderSession = in.RecipientSession.String()
+	}
+	return meta
+}
+
+func receiptKey(sender, recipient uuid.UUID) string {
+	return receiptsPrefix + sender.String() + ":" + recipient.String()
+}
+
+func receiptState(with uuid.UUID, hash map[string]string) dto.ReceiptState {
+	return dto.ReceiptState{
+		With:         with,
+		ReceivedUpTo: hash[receiptFieldReceived],
+		ReadUpTo:     hash[receiptFieldRead],
+	}
+}
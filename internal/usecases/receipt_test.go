@@ -0,0 +1,245 @@
+package usecases
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DENFNC/devPractice/internal/domain"
+	"github.com/DENFNC/devPractice/internal/dto"
+	"github.com/google/uuid"
+)
+
+// HashMax повторяет скрипт Redis: поле поднимается, только если новое
+// значение больше текущего при строковом сравнении.
+func (m *memoryKV) HashMax(_ context.Context, key string, fields map[string]string, _ time.Duration) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	hash := m.hashes[key]
+	if hash == nil {
+		hash = make(map[string]string)
+		m.hashes[key] = hash
+	}
+	for field, value := range fields {
+		if current, ok := hash[field]; !ok || current < value {
+			hash[field] = value
+		}
+	}
+	return maps.Clone(hash), nil
+}
+
+func (m *memoryKV) HashGetAll(_ context.Context, key string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.hashes[key]), nil
+}
+
+// stateNotifier запоминает уведомления о квитанциях.
+type stateNotifier struct {
+	mu     sync.Mutex
+	states []dto.ReceiptState
+}
+
+func (n *stateNotifier) Notify(_ context.Context, _, _, _ string, payload any) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.states = append(n.states, payload.(dto.ReceiptState))
+	return nil
+}
+
+// uuidV7At возвращает UUIDv7 со временем создания at.
+func uuidV7At(at time.Time) uuid.UUID {
+	id := uuid.New()
+	var ms [8]byte
+	binary.BigEndian.PutUint64(ms[:], uint64(at.UnixMilli()))
+	copy(id[:6], ms[2:])
+	id[6] = id[6]&0x0f | 0x70
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+func TestUUIDv7StringOrderFollowsCreationTime(t *testing.T) {
+	base := time.UnixMilli(1_700_000_000_255)
+	times := []time.Time{
+		base,
+		base.Add(time.Millisecond),
+		base.Add(time.Second),
+		base.Add(time.Hour),
+		base.Add(365 * 24 * time.Hour),
+	}
+
+	ids := make([]string, 0, len(times))
+	for _, at := range times {
+		id := uuidV7At(at)
+		if id.Version() != 7 {
+			t.Fatalf("uuidV7At built version %d", id.Version())
+		}
+		ids = append(ids, id.String())
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Fatalf("ids created in order are not sorted as strings: %v", ids)
+	}
+}
+
+func newReceiptUsecase(bus *recordingEventbus, store *memoryKV, notifier Notifier) *ReceiptUsecase {
+	return NewReceiptUsecase(&ReceiptUsecaseDeps{
+		Eventbus:   bus,
+		Notifier:   notifier,
+		Store:      store,
+		MessageTTL: time.Hour,
+	})
+}
+
+func TestAcknowledgeVerifiesParticipants(t *testing.T) {
+	sender, recipient := uuid.New(), uuid.New()
+	fresh := uuidV7At(time.Now())
+
+	tests := []struct {
+		name      string
+		messageID uuid.UUID
+		stored    bool
+		sender    uuid.UUID
+		recipient uuid.UUID
+		want      dto.SendStatus
+		wantErr   error
+	}{
+		{
+			name:      "participants match",
+			messageID: fresh,
+			stored:    true,
+			sender:    sender,
+			recipient: recipient,
+			want:      dto.SendStatusPublished,
+		},
+		{
+			name:      "another sender",
+			messageID: fresh,
+			stored:    true,
+			sender:    uuid.New(),
+			recipient: recipient,
+			want:      dto.SendStatusFailed,
+			wantErr:   domain.ErrUnknownMessage,
+		},
+		{
+			name:      "another recipient",
+			messageID: fresh,
+			stored:    true,
+			sender:    sender,
+			recipient: uuid.New(),
+			want:      dto.SendStatusFailed,
+			wantErr:   domain.ErrUnknownMessage,
+		},
+		{
+			name:      "unknown recent message",
+			messageID: fresh,
+			sender:    sender,
+			recipient: recipient,
+			want:      dto.SendStatusFailed,
+			wantErr:   domain.ErrUnknownMessage,
+		},
+		{
+			name:      "message older than the participants ttl",
+			messageID: uuidV7At(time.Now().Add(-2 * time.Hour)),
+			sender:    sender,
+			recipient: recipient,
+			want:      dto.SendStatusIgnored,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			bus := &recordingEventbus{}
+			store := newMemoryKV()
+			if tt.stored {
+				_ = store.HashSet(ctx, messagePrefix+tt.messageID.String(), map[string]string{
+					messageFieldSender:    sender.String(),
+					messageFieldRecipient: recipient.String(),
+				}, 0)
+			}
+			uc := newReceiptUsecase(bus, store, nil)
+
+			status, err := uc.Acknowledge(ctx, &dto.ReceiptEvent{
+				Kind:      dto.ReceiptReceived,
+				MessageID: tt.messageID,
+				Sender:    tt.sender,
+				Recipient: tt.recipient,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Acknowledge error = %v, want %v", err, tt.wantErr)
+			}
+			if status != tt.want {
+				t.Fatalf("status = %s, want %s", status, tt.want)
+			}
+			wantPublished := 0
+			if tt.want == dto.SendStatusPublished {
+				wantPublished = 1
+			}
+			if len(bus.published) != wantPublished {
+				t.Fatalf("published %d receipts, want %d", len(bus.published), wantPublished)
+			}
+		})
+	}
+}
+
+func TestHandleReceiptKeepsHighestMessageID(t *testing.T) {
+	ctx := context.Background()
+	sender, recipient := uuid.New(), uuid.New()
+	base := time.Now().Add(-time.Minute)
+	first, second, third := uuidV7At(base), uuidV7At(base.Add(time.Millisecond)), uuidV7At(base.Add(time.Second))
+
+	bus := &recordingEventbus{}
+	store := newMemoryKV()
+	notifier := &stateNotifier{}
+	uc := newReceiptUsecase(bus, store, notifier)
+
+	steps := []struct {
+		kind         dto.ReceiptKind
+		messageID    uuid.UUID
+		wantReceived uuid.UUID
+		wantRead     uuid.UUID
+	}{
+		{kind: dto.ReceiptRead, messageID: second, wantReceived: second, wantRead: second},
+		// Запоздавшие квитанции не откатывают состояние.
+		{kind: dto.ReceiptReceived, messageID: first, wantReceived: second, wantRead: second},
+		{kind: dto.ReceiptRead, messageID: first, wantReceived: second, wantRead: second},
+		{kind: dto.ReceiptReceived, messageID: third, wantReceived: third, wantRead: second},
+		{kind: dto.ReceiptRead, messageID: third, wantReceived: third, wantRead: third},
+	}
+
+	for i, step := range steps {
+		_ = store.HashSet(ctx, messagePrefix+step.messageID.String(), map[string]string{
+			messageFieldSender:    sender.String(),
+			messageFieldRecipient: recipient.String(),
+		}, 0)
+		if _, err := uc.Acknowledge(ctx, &dto.ReceiptEvent{
+			Kind:      step.kind,
+			MessageID: step.messageID,
+			Sender:    sender,
+			Recipient: recipient,
+		}); err != nil {
+			t.Fatalf("step %d: Acknowledge: %v", i, err)
+		}
+		if err := uc.HandleReceipt(ctx, bus.published[len(bus.published)-1]); err != nil {
+			t.Fatalf("step %d: HandleReceipt: %v", i, err)
+		}
+
+		state := notifier.states[len(notifier.states)-1]
+		if state.ReceivedUpTo != step.wantReceived.String() || state.ReadUpTo != step.wantRead.String() {
+			t.Fatalf("step %d: state = %+v, want received %s, read %s", i, state, step.wantReceived, step.wantRead)
+		}
+	}
+
+	got, err := uc.Receipts(ctx, sender, recipient)
+	if err != nil {
+		t.Fatalf("Receipts: %v", err)
+	}
+	if got.With != recipient || got.ReceivedUpTo != third.String() || got.ReadUpTo != third.String() {
+		t.Fatalf("Receipts = %+v, want everything up to %s", got, third)
+	}
+}
@@ -54,3 +54,20 @@ func (b *Backoff) Reset() {
 func (b *Backoff) Attempts() int {
 	return b.cfg.Attempts
 }
+
+// Delay возвращает задержку перед попыткой attempt (начиная с 1) без
+// джиттера: Initial * Factor^(attempt-1), но не больше Max. Удобна, когда
+// задержка должна быть детерминированной, например для топиков повторов.
+//
+// Пример:
+//
+//	retry.Delay(&config.RetryConfig{Initial: time.Second, Factor: 2}, 3) // 4s
+func Delay(cfg *config.RetryConfig, attempt int) time.Duration {
+	sanitized := sanitize(cfg)
+
+	delay := sanitized.Initial
+	for i := 1; i < attempt && delay < sanitized.Max; i++ {
+		delay = time.Duration(float64(delay) * sanitized.Factor)
+	}
+	return min(delay, sanitized.Max)
+}